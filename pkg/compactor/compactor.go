@@ -0,0 +1,231 @@
+// Package compactor compacts a store in the background as the
+// auto-compaction of etcd does. It is the supported way to compact the
+// revisions older than a duration: etcd keeps no creation time of revisions,
+// so a Periodic compactor samples the current revision and compacts to the
+// one it observed a retention period ago.
+package compactor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+
+	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
+)
+
+// Mode selects how the retention of a Compactor is measured, following the
+// --auto-compaction-mode flag of etcd.
+type Mode string
+
+const (
+	// Periodic keeps the revisions created during the retention period.
+	Periodic Mode = "periodic"
+	// Revision keeps the latest retention revisions.
+	Revision Mode = "revision"
+)
+
+const (
+	defaultRevisionInterval = 5 * time.Minute
+	maxPeriodicInterval     = time.Hour
+)
+
+type Option func(*Compactor)
+
+// WithPhysical makes every compaction wait until the compacted revisions are
+// physically removed from the backend.
+func WithPhysical(physical bool) Option {
+	return func(c *Compactor) {
+		c.physical = physical
+	}
+}
+
+// WithInterval overrides how often the compactor checks the current revision.
+// By default it is five minutes in revision mode and a tenth of the retention,
+// but at most an hour, in periodic mode.
+func WithInterval(interval time.Duration) Option {
+	return func(c *Compactor) {
+		if interval > 0 {
+			c.interval = interval
+		}
+	}
+}
+
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Compactor) {
+		c.logger = logger
+	}
+}
+
+type Stats struct {
+	Compactions int
+	Errors      int
+	Revision    int64
+}
+
+// Compactor compacts a KV in the background the way etcd's auto compaction
+// does, so a benchmark can run under the same compaction pressure.
+type Compactor struct {
+	kv       etcd.KV
+	mode     Mode
+	period   time.Duration
+	revision int64
+	interval time.Duration
+	physical bool
+	logger   *slog.Logger
+
+	mu    sync.Mutex
+	stats Stats
+}
+
+// New creates a compactor from etcd style flags: the retention is a duration
+// such as "30m" or a number of hours in periodic mode, and a number of
+// revisions in revision mode.
+func New(kv etcd.KV, mode Mode, retention string, opts ...Option) (*Compactor, error) {
+	switch mode {
+	case Periodic:
+		if hours, err := strconv.ParseInt(retention, 10, 64); err == nil {
+			return NewPeriodic(kv, time.Duration(hours)*time.Hour, opts...)
+		}
+		period, err := time.ParseDuration(retention)
+		if err != nil {
+			return nil, fmt.Errorf("compactor: invalid periodic retention %q: %w", retention, err)
+		}
+		return NewPeriodic(kv, period, opts...)
+	case Revision:
+		revisions, err := strconv.ParseInt(retention, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("compactor: invalid revision retention %q: %w", retention, err)
+		}
+		return NewRevision(kv, revisions, opts...)
+	default:
+		return nil, fmt.Errorf("compactor: unknown mode %q", mode)
+	}
+}
+
+func NewPeriodic(kv etcd.KV, period time.Duration, opts ...Option) (*Compactor, error) {
+	if period <= 0 {
+		return nil, fmt.Errorf("compactor: periodic retention must be positive, got %s", period)
+	}
+	interval := min(period/10, maxPeriodicInterval)
+	return newCompactor(kv, Periodic, period, 0, interval, opts), nil
+}
+
+func NewRevision(kv etcd.KV, revisions int64, opts ...Option) (*Compactor, error) {
+	if revisions <= 0 {
+		return nil, fmt.Errorf("compactor: revision retention must be positive, got %d", revisions)
+	}
+	return newCompactor(kv, Revision, 0, revisions, defaultRevisionInterval, opts), nil
+}
+
+func newCompactor(kv etcd.KV, mode Mode, period time.Duration, revision int64, interval time.Duration, opts []Option) *Compactor {
+	c := &Compactor{
+		kv:       kv,
+		mode:     mode,
+		period:   period,
+		revision: revision,
+		interval: interval,
+		logger:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func (c *Compactor) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// Run compacts until ctx is done.
+func (c *Compactor) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	// samples are the revisions observed in periodic mode, oldest first.
+	var samples []sample
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			current, err := c.currentRevision(ctx)
+			if err != nil {
+				c.fail(ctx, err)
+				continue
+			}
+			target := current - c.revision
+			if c.mode == Periodic {
+				samples = append(samples, sample{time: now, revision: current})
+				target, samples = expired(samples, now.Add(-c.period))
+			}
+			if target > c.Stats().Revision {
+				c.compact(ctx, target)
+			}
+		}
+	}
+}
+
+type sample struct {
+	time     time.Time
+	revision int64
+}
+
+// expired returns the latest revision observed before deadline, dropping the
+// samples older than it.
+func expired(samples []sample, deadline time.Time) (int64, []sample) {
+	i := 0
+	for i < len(samples) && !samples[i].time.After(deadline) {
+		i++
+	}
+	if i == 0 {
+		return 0, samples
+	}
+	return samples[i-1].revision, samples[i-1:]
+}
+
+func (c *Compactor) currentRevision(ctx context.Context) (int64, error) {
+	response, err := etcd.Range(ctx, c.kv, &etcd.RangeRequest{Key: etcd.EmptyKey, Limit: 1, CountOnly: true})
+	if err != nil {
+		return 0, err
+	}
+	return response.Revision, nil
+}
+
+func (c *Compactor) compact(ctx context.Context, revision int64) {
+	start := time.Now()
+	_, err := etcd.Compact(ctx, c.kv, &etcd.CompactRequest{Revision: revision, Physical: c.physical})
+	if errors.Is(err, rpctypes.ErrGRPCCompacted) {
+		// Someone else has already compacted past the revision.
+		c.mu.Lock()
+		c.stats.Revision = revision
+		c.mu.Unlock()
+		return
+	} else if err != nil {
+		c.fail(ctx, err)
+		return
+	}
+	c.mu.Lock()
+	c.stats.Compactions++
+	c.stats.Revision = revision
+	c.mu.Unlock()
+	c.logger.InfoContext(ctx, "compacted", "mode", c.mode, "revision", revision, "physical", c.physical, "duration", time.Since(start))
+}
+
+func (c *Compactor) fail(ctx context.Context, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	c.mu.Lock()
+	c.stats.Errors++
+	c.mu.Unlock()
+	c.logger.WarnContext(ctx, "compaction failed", "mode", c.mode, "error", err)
+}
@@ -0,0 +1,235 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+
+	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
+)
+
+var (
+	ErrElectionNotLeader = errors.New("election: not leader")
+	ErrElectionNoLeader  = errors.New("election: no leader")
+)
+
+// Election is a leader election. Like Mutex, every candidate creates a key
+// under the prefix attached to its session lease and the candidate with the
+// oldest key is the leader; the value of its key is the proclaimed one.
+type Election struct {
+	session *Session
+
+	prefix   string
+	key      string
+	revision int64
+	leader   *Session
+}
+
+func NewElection(session *Session, prefix string) *Election {
+	return &Election{session: session, prefix: prefix + "/"}
+}
+
+// ResumeElection initializes an election with a known leader.
+func ResumeElection(session *Session, prefix string, key string, revision int64) *Election {
+	return &Election{
+		session:  session,
+		prefix:   prefix + "/",
+		key:      key,
+		revision: revision,
+		leader:   session,
+	}
+}
+
+// Campaign puts a value as eligible for the election and waits until it is
+// elected. A canceled ctx withdraws the candidate.
+func (e *Election) Campaign(ctx context.Context, value string) error {
+	client := e.session.Client()
+	lease := e.session.Lease()
+	key := fmt.Sprintf("%s%x", e.prefix, lease)
+	response, err := etcd.Txn(ctx, client, &etcd.TxnRequest{
+		Compare: []etcd.Compare{etcd.Compare{Key: key}.Equal().SetCreateRevision(0)},
+		Success: []etcd.Request{&etcd.PutRequest{Key: key, Value: value, Lease: lease}},
+		Failure: []etcd.Request{&etcd.RangeRequest{Key: key}},
+	})
+	if err != nil {
+		return err
+	}
+	e.key, e.revision, e.leader = key, response.Revision, e.session
+	if !response.Succeeded {
+		kv := response.Responses[0].(*etcd.RangeResponse).Kvs[0]
+		e.revision = kv.CreateRevision
+		if kv.Value != value {
+			if err := e.Proclaim(ctx, value); err != nil {
+				e.Resign(ctx)
+				return err
+			}
+		}
+	}
+
+	if err := waitDeletes(ctx, client, e.prefix, e.revision-1); err != nil {
+		select {
+		case <-ctx.Done():
+			e.Resign(e.session.Ctx())
+		default:
+			e.leader = nil
+		}
+		return err
+	}
+	return nil
+}
+
+// Proclaim lets the leader announce a new value without another election.
+func (e *Election) Proclaim(ctx context.Context, value string) error {
+	if e.leader == nil {
+		return ErrElectionNotLeader
+	}
+	response, err := etcd.Txn(ctx, e.session.Client(), &etcd.TxnRequest{
+		Compare: []etcd.Compare{etcd.Compare{Key: e.key}.Equal().SetCreateRevision(e.revision)},
+		Success: []etcd.Request{&etcd.PutRequest{Key: e.key, Value: value, Lease: e.leader.Lease()}},
+	})
+	if err != nil {
+		return err
+	}
+	if !response.Succeeded {
+		e.key = ""
+		return ErrElectionNotLeader
+	}
+	return nil
+}
+
+// Resign lets the leader start a new election.
+func (e *Election) Resign(ctx context.Context) error {
+	if e.leader == nil {
+		return nil
+	}
+	_, err := etcd.Txn(ctx, e.session.Client(), &etcd.TxnRequest{
+		Compare: []etcd.Compare{etcd.Compare{Key: e.key}.Equal().SetCreateRevision(e.revision)},
+		Success: []etcd.Request{&etcd.DeleteRequest{Key: e.key}},
+	})
+	e.key, e.leader = "", nil
+	return err
+}
+
+// Leader returns the key of the current leader.
+func (e *Election) Leader(ctx context.Context) (*etcd.KeyValue, error) {
+	response, err := etcd.Range(ctx, e.session.Client(), firstCreated(e.prefix))
+	if err != nil {
+		return nil, err
+	}
+	if len(response.Kvs) == 0 {
+		return nil, ErrElectionNoLeader
+	}
+	return response.Kvs[0], nil
+}
+
+// Observe returns a channel that receives the key of the leader every time
+// the leader or its proclaimed value changes. The channel is closed when ctx
+// is done or watching fails.
+func (e *Election) Observe(ctx context.Context) <-chan *etcd.KeyValue {
+	ch := make(chan *etcd.KeyValue)
+	go e.observe(ctx, ch)
+	return ch
+}
+
+func (e *Election) observe(ctx context.Context, ch chan<- *etcd.KeyValue) {
+	defer close(ch)
+	client := e.session.Client()
+	for {
+		response, err := etcd.Range(ctx, client, firstCreated(e.prefix))
+		if err != nil {
+			return
+		}
+		var leader *etcd.KeyValue
+		revision := response.Revision
+		if len(response.Kvs) != 0 {
+			leader = response.Kvs[0]
+		} else if leader, err = e.waitLeader(ctx, revision); err != nil {
+			return
+		} else {
+			revision = leader.ModRevision
+		}
+
+		select {
+		case ch <- leader:
+		case <-ctx.Done():
+			return
+		}
+		if err := e.watchLeader(ctx, leader.Key, revision+1, ch); err != nil {
+			return
+		}
+	}
+}
+
+// waitLeader waits for the first key put under the prefix after revision.
+func (e *Election) waitLeader(ctx context.Context, revision int64) (*etcd.KeyValue, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	watch, err := etcd.Watch(ctx, e.session.Client(), &etcd.WatchRequest{
+		Key:           e.prefix,
+		RangeEnd:      etcd.GetPrefix(e.prefix),
+		StartRevision: revision,
+		NoDelete:      true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for response := range watch {
+		if response.Err != nil {
+			return nil, response.Err
+		}
+		if response.Canceled {
+			break
+		}
+		for _, event := range response.Events {
+			if event.Type == mvccpb.PUT {
+				return event.Kv, nil
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errLostWatcher
+}
+
+// watchLeader sends every new value of the leader key until it is deleted.
+func (e *Election) watchLeader(ctx context.Context, key string, revision int64, ch chan<- *etcd.KeyValue) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	watch, err := etcd.Watch(ctx, e.session.Client(), &etcd.WatchRequest{Key: key, StartRevision: revision})
+	if err != nil {
+		return err
+	}
+	for response := range watch {
+		if response.Err != nil {
+			return response.Err
+		}
+		if response.Canceled {
+			break
+		}
+		for _, event := range response.Events {
+			if event.Type == mvccpb.DELETE {
+				return nil
+			}
+			select {
+			case ch <- event.Kv:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return errLostWatcher
+}
+
+func (e *Election) Key() string {
+	return e.key
+}
+
+func (e *Election) Revision() int64 {
+	return e.revision
+}
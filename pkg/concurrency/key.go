@@ -0,0 +1,72 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.etcd.io/etcd/api/v3/mvccpb"
+
+	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
+)
+
+var errLostWatcher = errors.New("concurrency: lost watcher waiting for delete")
+
+func waitDelete(ctx context.Context, client Client, key string, revision int64) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	watch, err := etcd.Watch(ctx, client, &etcd.WatchRequest{Key: key, StartRevision: revision})
+	if err != nil {
+		return err
+	}
+	for response := range watch {
+		if response.Err != nil {
+			return response.Err
+		}
+		if response.Canceled {
+			return fmt.Errorf("concurrency: watch on %q canceled: %s", key, response.CancelReason)
+		}
+		for _, event := range response.Events {
+			if event.Type == mvccpb.DELETE {
+				return nil
+			}
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return errLostWatcher
+}
+
+// waitDeletes waits until every key under prefix created at or before
+// maxCreateRevision is deleted, watching the latest created one at a time.
+func waitDeletes(ctx context.Context, client Client, prefix string, maxCreateRevision int64) error {
+	request := etcd.RangeRequest{
+		Key:               prefix,
+		RangeEnd:          etcd.GetPrefix(prefix),
+		Limit:             1,
+		MaxCreateRevision: maxCreateRevision,
+	}.OrderByCreateRevision().Descending()
+	for {
+		response, err := etcd.Range(ctx, client, request)
+		if err != nil {
+			return err
+		}
+		if len(response.Kvs) == 0 {
+			return nil
+		}
+		if err := waitDelete(ctx, client, response.Kvs[0].Key, response.Revision); err != nil {
+			return err
+		}
+	}
+}
+
+// firstCreated is a request for the oldest key under prefix.
+func firstCreated(prefix string) *etcd.RangeRequest {
+	return etcd.RangeRequest{
+		Key:      prefix,
+		RangeEnd: etcd.GetPrefix(prefix),
+		Limit:    1,
+	}.OrderByCreateRevision().Ascending()
+}
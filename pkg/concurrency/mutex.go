@@ -0,0 +1,145 @@
+package concurrency
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
+)
+
+var (
+	ErrLocked         = errors.New("mutex: locked by another session")
+	ErrSessionExpired = errors.New("mutex: session is expired")
+)
+
+// Mutex is a distributed lock. Every contender creates a key under the prefix
+// attached to its session lease; the owner is the contender with the oldest
+// key, and the others wait for the deletion of the key created right before
+// theirs.
+type Mutex struct {
+	session *Session
+
+	prefix   string
+	key      string
+	revision int64
+}
+
+func NewMutex(session *Session, prefix string) *Mutex {
+	return &Mutex{session: session, prefix: prefix + "/", revision: -1}
+}
+
+// TryLock locks the mutex if it is not held by another session and returns
+// ErrLocked otherwise.
+func (m *Mutex) TryLock(ctx context.Context) error {
+	response, err := m.tryAcquire(ctx)
+	if err != nil {
+		return err
+	}
+	if m.isOwner(response) {
+		return nil
+	}
+	client := m.session.Client()
+	if _, err := etcd.Delete(ctx, client, &etcd.DeleteRequest{Key: m.key}); err != nil {
+		return err
+	}
+	m.key, m.revision = etcd.EmptyKey, -1
+	return ErrLocked
+}
+
+// Lock locks the mutex, waiting until every contender that came earlier
+// releases it.
+func (m *Mutex) Lock(ctx context.Context) error {
+	response, err := m.tryAcquire(ctx)
+	if err != nil {
+		return err
+	}
+	if m.isOwner(response) {
+		return nil
+	}
+
+	client := m.session.Client()
+	if err := waitDeletes(ctx, client, m.prefix, m.revision-1); err != nil {
+		m.Unlock(m.session.Ctx())
+		return err
+	}
+	// The session could have expired while waiting, taking our key with it.
+	current, err := etcd.Range(ctx, client, &etcd.RangeRequest{Key: m.key})
+	if err != nil {
+		m.Unlock(m.session.Ctx())
+		return err
+	}
+	if len(current.Kvs) == 0 {
+		return ErrSessionExpired
+	}
+	return nil
+}
+
+func (m *Mutex) tryAcquire(ctx context.Context) (*etcd.TxnResponse, error) {
+	lease := m.session.Lease()
+	m.key = fmt.Sprintf("%s%x", m.prefix, lease)
+	response, err := etcd.Txn(ctx, m.session.Client(), &etcd.TxnRequest{
+		Compare: []etcd.Compare{etcd.Compare{Key: m.key}.Equal().SetCreateRevision(0)},
+		Success: []etcd.Request{
+			&etcd.PutRequest{Key: m.key, Lease: lease},
+			firstCreated(m.prefix),
+		},
+		Failure: []etcd.Request{
+			&etcd.RangeRequest{Key: m.key},
+			firstCreated(m.prefix),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.revision = response.Revision
+	if !response.Succeeded {
+		m.revision = response.Responses[0].(*etcd.RangeResponse).Kvs[0].CreateRevision
+	}
+	return response, nil
+}
+
+func (m *Mutex) isOwner(response *etcd.TxnResponse) bool {
+	owner := response.Responses[1].(*etcd.RangeResponse).Kvs
+	return len(owner) == 0 || owner[0].CreateRevision == m.revision
+}
+
+func (m *Mutex) Unlock(ctx context.Context) error {
+	if _, err := etcd.Delete(ctx, m.session.Client(), &etcd.DeleteRequest{Key: m.key}); err != nil {
+		return err
+	}
+	m.key, m.revision = etcd.EmptyKey, -1
+	return nil
+}
+
+// IsOwner is a compare that holds while the mutex is locked by this session,
+// to guard txns that must only run under the lock.
+func (m *Mutex) IsOwner() etcd.Compare {
+	return etcd.Compare{Key: m.key}.Equal().SetCreateRevision(m.revision)
+}
+
+func (m *Mutex) Key() string {
+	return m.key
+}
+
+type lockerMutex struct {
+	*Mutex
+}
+
+func (m *lockerMutex) Lock() {
+	if err := m.Mutex.Lock(m.session.Ctx()); err != nil {
+		panic(err)
+	}
+}
+
+func (m *lockerMutex) Unlock() {
+	if err := m.Mutex.Unlock(m.session.Ctx()); err != nil {
+		panic(err)
+	}
+}
+
+// NewLocker creates a sync.Locker backed by a Mutex.
+func NewLocker(session *Session, prefix string) sync.Locker {
+	return &lockerMutex{Mutex: NewMutex(session, prefix)}
+}
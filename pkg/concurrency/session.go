@@ -0,0 +1,132 @@
+package concurrency
+
+import (
+	"context"
+	"time"
+
+	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
+)
+
+const defaultSessionTTL = 60
+
+// Client is the part of the etcd API the recipes are built on.
+type Client interface {
+	etcd.KV
+	etcd.Lease
+	etcd.Watcher
+}
+
+type SessionOption func(*sessionOptions)
+
+type sessionOptions struct {
+	ttl     int64
+	leaseID int64
+	ctx     context.Context
+}
+
+// WithTTL sets the TTL in seconds of the session lease.
+func WithTTL(ttl int64) SessionOption {
+	return func(o *sessionOptions) {
+		if ttl > 0 {
+			o.ttl = ttl
+		}
+	}
+}
+
+// WithLease makes the session keep alive an existing lease instead of
+// granting a new one.
+func WithLease(leaseID int64) SessionOption {
+	return func(o *sessionOptions) {
+		o.leaseID = leaseID
+	}
+}
+
+// WithContext bounds the session by ctx: the lease stops being kept alive
+// once ctx is done.
+func WithContext(ctx context.Context) SessionOption {
+	return func(o *sessionOptions) {
+		o.ctx = ctx
+	}
+}
+
+// Session is a lease kept alive for as long as the session lives. Keys
+// written by the recipes are attached to it, so they disappear when the
+// session is closed or its owner stops refreshing the lease.
+type Session struct {
+	client Client
+	opts   sessionOptions
+	id     int64
+
+	cancel context.CancelFunc
+	donec  <-chan struct{}
+}
+
+func NewSession(client Client, opts ...SessionOption) (*Session, error) {
+	o := sessionOptions{ttl: defaultSessionTTL, ctx: context.Background()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	id := o.leaseID
+	if id == 0 {
+		response, err := etcd.LeaseGrant(o.ctx, client, &etcd.LeaseGrantRequest{TTL: o.ttl})
+		if err != nil {
+			return nil, err
+		}
+		id = response.ID
+	}
+
+	ctx, cancel := context.WithCancel(o.ctx)
+	keepAlive, err := etcd.LeaseKeepAlive(ctx, client, id)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	donec := make(chan struct{})
+	go func() {
+		defer close(donec)
+		for range keepAlive {
+		}
+	}()
+
+	return &Session{
+		client: client,
+		opts:   o,
+		id:     id,
+		cancel: cancel,
+		donec:  donec,
+	}, nil
+}
+
+func (s *Session) Client() Client {
+	return s.client
+}
+
+func (s *Session) Lease() int64 {
+	return s.id
+}
+
+func (s *Session) Ctx() context.Context {
+	return s.opts.ctx
+}
+
+// Done is closed when the lease is no longer kept alive.
+func (s *Session) Done() <-chan struct{} {
+	return s.donec
+}
+
+// Orphan stops keeping the lease alive without revoking it, so the keys of
+// the session expire together with the lease.
+func (s *Session) Orphan() {
+	s.cancel()
+	<-s.donec
+}
+
+// Close orphans the session and revokes its lease.
+func (s *Session) Close() error {
+	s.Orphan()
+	ctx, cancel := context.WithTimeout(s.opts.ctx, time.Duration(s.opts.ttl)*time.Second)
+	defer cancel()
+	_, err := etcd.LeaseRevoke(ctx, s.client, &etcd.LeaseRevokeRequest{ID: s.id})
+	return err
+}
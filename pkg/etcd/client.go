@@ -2,53 +2,70 @@ package etcd
 
 import (
 	"context"
-	"os"
+	"fmt"
 
 	"go.etcd.io/etcd/api/v3/etcdserverpb"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
-	"google.golang.org/grpc/grpclog"
+	_ "google.golang.org/grpc/health"
+	"google.golang.org/grpc/resolver"
+	"google.golang.org/grpc/resolver/manual"
 )
 
-type Request interface {
-	Request()
-}
-
-type Response interface {
-	Response()
-	GetRevision() int64
-	IsWrite() bool
-}
-
-var defaultCallOpts = []grpc.CallOption{
-	grpc.WaitForReady(true),
-	grpc.MaxCallSendMsgSize(2 * 1024 * 1024),
-	grpc.MaxCallRecvMsgSize(4 * 1024 * 1024),
-}
-
 type Client struct {
-	endpoint string
-	callOpts []grpc.CallOption
-	kv       etcdserverpb.KVClient
+	endpoints []string
+	callOpts  []grpc.CallOption
+	conn      *grpc.ClientConn
+	kv        etcdserverpb.KVClient
+	lease     etcdserverpb.LeaseClient
+	watch     etcdserverpb.WatchClient
 }
 
-func NewClient(endpoint string) (*Client, error) {
-	grpclog.SetLoggerV2(grpclog.NewLoggerV2(os.Stderr, os.Stderr, os.Stderr))
+func NewClient(endpoints []string, opts ...Option) (*Client, error) {
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("no endpoints")
+	}
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if err := o.validate(); err != nil {
+		return nil, err
+	}
 
-	opts := []grpc.DialOption{
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	addresses := make([]resolver.Address, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		addresses = append(addresses, resolver.Address{Addr: endpoint})
 	}
-	conn, err := grpc.NewClient(endpoint, opts...)
+	r := manual.NewBuilderWithScheme("etcd")
+	r.InitialState(resolver.State{Addresses: addresses})
+
+	conn, err := grpc.NewClient(r.Scheme()+":///", append(o.dialOptions(), grpc.WithResolvers(r))...)
 	if err != nil {
 		return nil, err
 	}
 	return &Client{
-		endpoint: endpoint,
-		callOpts: defaultCallOpts,
-		kv:       etcdserverpb.NewKVClient(conn),
+		endpoints: endpoints,
+		callOpts:  o.callOptions(),
+		conn:      conn,
+		kv:        etcdserverpb.NewKVClient(conn),
+		lease:     etcdserverpb.NewLeaseClient(conn),
+		watch:     etcdserverpb.NewWatchClient(conn),
 	}, nil
 }
 
+func (client *Client) Endpoints() []string {
+	return client.endpoints
+}
+
+// Conn is the connection the client sends its calls over.
+func (client *Client) Conn() *grpc.ClientConn {
+	return client.conn
+}
+
+func (client *Client) Close() error {
+	return client.conn.Close()
+}
+
 func (client *Client) Range(ctx context.Context, request *etcdserverpb.RangeRequest) (*etcdserverpb.RangeResponse, error) {
 	return client.kv.Range(ctx, request, client.callOpts...)
 }
@@ -69,19 +86,22 @@ func (client *Client) Compact(ctx context.Context, request *etcdserverpb.Compact
 	return client.kv.Compact(ctx, request, client.callOpts...)
 }
 
-func Do(ctx context.Context, client *Client, request Request) (Response, error) {
-	switch r := request.(type) {
-	case *CompactRequest:
-		return Compact(ctx, client, r)
-	case *DeleteRequest:
-		return Delete(ctx, client, r)
-	case *PutRequest:
-		return Put(ctx, client, r)
-	case *RangeRequest:
-		return Range(ctx, client, r)
-	case *TxnRequest:
-		return Txn(ctx, client, r)
-	default:
-		panic("unknown request type")
-	}
+func (client *Client) LeaseGrant(ctx context.Context, request *etcdserverpb.LeaseGrantRequest) (*etcdserverpb.LeaseGrantResponse, error) {
+	return client.lease.LeaseGrant(ctx, request, client.callOpts...)
+}
+
+func (client *Client) LeaseRevoke(ctx context.Context, request *etcdserverpb.LeaseRevokeRequest) (*etcdserverpb.LeaseRevokeResponse, error) {
+	return client.lease.LeaseRevoke(ctx, request, client.callOpts...)
+}
+
+func (client *Client) LeaseTimeToLive(ctx context.Context, request *etcdserverpb.LeaseTimeToLiveRequest) (*etcdserverpb.LeaseTimeToLiveResponse, error) {
+	return client.lease.LeaseTimeToLive(ctx, request, client.callOpts...)
+}
+
+func (client *Client) LeaseKeepAlive(ctx context.Context) (etcdserverpb.Lease_LeaseKeepAliveClient, error) {
+	return client.lease.LeaseKeepAlive(ctx, client.callOpts...)
+}
+
+func (client *Client) Watch(ctx context.Context) (etcdserverpb.Watch_WatchClient, error) {
+	return client.watch.Watch(ctx, client.callOpts...)
 }
@@ -7,7 +7,8 @@ import (
 )
 
 type CompactRequest struct {
-	Revision int64
+	Revision int64 `json:"revision,omitempty"`
+	Physical bool  `json:"physical,omitempty"`
 }
 
 func (CompactRequest) Request() {}
@@ -18,11 +19,22 @@ func serializeCompactRequest(request *CompactRequest) *etcdserverpb.CompactionRe
 	}
 	return &etcdserverpb.CompactionRequest{
 		Revision: request.Revision,
+		Physical: request.Physical,
+	}
+}
+
+func deserializeCompactRequest(request *etcdserverpb.CompactionRequest) *CompactRequest {
+	if request == nil {
+		return nil
+	}
+	return &CompactRequest{
+		Revision: request.Revision,
+		Physical: request.Physical,
 	}
 }
 
 type CompactResponse struct {
-	Revision int64
+	Revision int64 `json:"revision,omitempty"`
 }
 
 func (CompactResponse) Response() {}
@@ -31,6 +43,8 @@ func (response CompactResponse) GetRevision() int64 {
 	return response.Revision
 }
 
+// IsWrite is false since compaction removes old revisions without creating a
+// new one.
 func (CompactResponse) IsWrite() bool {
 	return false
 }
@@ -40,14 +54,42 @@ func deserializeCompactResponse(response *etcdserverpb.CompactionResponse) *Comp
 		return nil
 	}
 	return &CompactResponse{
-		Revision: response.Header.Revision,
+		Revision: response.GetHeader().GetRevision(),
 	}
 }
 
-func Compact(ctx context.Context, client *Client, request *CompactRequest) (*CompactResponse, error) {
-	response, err := client.Compact(ctx, serializeCompactRequest(request))
+func serializeCompactResponse(response *CompactResponse) *etcdserverpb.CompactionResponse {
+	if response == nil {
+		return nil
+	}
+	return &etcdserverpb.CompactionResponse{
+		Header: &etcdserverpb.ResponseHeader{Revision: response.Revision},
+	}
+}
+
+func Compact(ctx context.Context, kv KV, request *CompactRequest) (*CompactResponse, error) {
+	if err := Validate(request); err != nil {
+		return nil, err
+	}
+	response, err := kv.Compact(ctx, serializeCompactRequest(request))
 	if err != nil {
 		return nil, err
 	}
 	return deserializeCompactResponse(response), nil
 }
+
+// CompactRetain compacts all but the latest retain revisions. It does nothing
+// and returns a nil response while the store has no more than retain of them.
+// Revisions carry no time, to compact those older than a duration run a
+// periodic compactor.Compactor instead.
+func CompactRetain(ctx context.Context, kv KV, retain int64, physical bool) (*CompactResponse, error) {
+	current, err := Range(ctx, kv, &RangeRequest{Key: EmptyKey, Limit: 1, CountOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	target := current.Revision - retain
+	if target <= 0 {
+		return nil, nil
+	}
+	return Compact(ctx, kv, &CompactRequest{Revision: target, Physical: physical})
+}
@@ -1,16 +1,23 @@
 package etcd
 
 import (
+	"errors"
+	"fmt"
+
 	"go.etcd.io/etcd/api/v3/etcdserverpb"
 )
 
+var ErrCompareTarget = errors.New("etcd: compare must have exactly one target")
+
 type Compare struct {
-	Key            string
-	Result         etcdserverpb.Compare_CompareResult
-	ModRevision    *int64
-	CreateRevision *int64
-	Version        *int64
-	Value          *string
+	Key            string                             `json:"key,omitempty"`
+	RangeEnd       string                             `json:"range_end,omitempty"`
+	Result         etcdserverpb.Compare_CompareResult `json:"result,omitempty"`
+	ModRevision    *int64                             `json:"mod_revision,omitempty"`
+	CreateRevision *int64                             `json:"create_revision,omitempty"`
+	Version        *int64                             `json:"version,omitempty"`
+	Value          *string                            `json:"value,omitempty"`
+	Lease          *int64                             `json:"lease,omitempty"`
 }
 
 func (compare Compare) Equal() Compare {
@@ -53,10 +60,47 @@ func (compare Compare) SetValue(value string) Compare {
 	return compare
 }
 
-func serializeCompare(compare Compare) *etcdserverpb.Compare {
+func (compare Compare) SetLease(lease int64) Compare {
+	compare.Lease = &lease
+	return compare
+}
+
+// WithRange makes the compare hold for every key in [Key, rangeEnd).
+func (compare Compare) WithRange(rangeEnd string) Compare {
+	compare.RangeEnd = rangeEnd
+	return compare
+}
+
+// WithPrefix makes the compare hold for every key prefixed by Key.
+func (compare Compare) WithPrefix() Compare {
+	compare.RangeEnd = GetPrefix(compare.Key)
+	return compare
+}
+
+func validateCompare(compare Compare) error {
+	targets := 0
+	for _, set := range []bool{
+		compare.ModRevision != nil,
+		compare.CreateRevision != nil,
+		compare.Version != nil,
+		compare.Value != nil,
+		compare.Lease != nil,
+	} {
+		if set {
+			targets++
+		}
+	}
+	if targets != 1 {
+		return fmt.Errorf("%w: key %q has %d", ErrCompareTarget, compare.Key, targets)
+	}
+	return nil
+}
+
+func serializeCompare(compare Compare) (*etcdserverpb.Compare, error) {
 	result := &etcdserverpb.Compare{
-		Key:    []byte(compare.Key),
-		Result: compare.Result,
+		Key:      []byte(compare.Key),
+		RangeEnd: []byte(compare.RangeEnd),
+		Result:   compare.Result,
 	}
 	switch {
 	case compare.ModRevision != nil:
@@ -71,8 +115,135 @@ func serializeCompare(compare Compare) *etcdserverpb.Compare {
 	case compare.Value != nil:
 		result.Target = etcdserverpb.Compare_VALUE
 		result.TargetUnion = &etcdserverpb.Compare_Value{Value: []byte(*compare.Value)}
+	case compare.Lease != nil:
+		result.Target = etcdserverpb.Compare_LEASE
+		result.TargetUnion = &etcdserverpb.Compare_Lease{Lease: *compare.Lease}
+	default:
+		return nil, ErrCompareTarget
+	}
+	return result, nil
+}
+
+func deserializeCompare(compare *etcdserverpb.Compare) Compare {
+	result := Compare{
+		Key:      string(compare.Key),
+		RangeEnd: string(compare.RangeEnd),
+		Result:   compare.Result,
+	}
+	switch compare.Target {
+	case etcdserverpb.Compare_MOD:
+		return result.SetModRevision(compare.GetModRevision())
+	case etcdserverpb.Compare_CREATE:
+		return result.SetCreateRevision(compare.GetCreateRevision())
+	case etcdserverpb.Compare_VERSION:
+		return result.SetVersion(compare.GetVersion())
+	case etcdserverpb.Compare_LEASE:
+		return result.SetLease(compare.GetLease())
+	default:
+		return result.SetValue(string(compare.GetValue()))
+	}
+}
+
+// NumericTarget is a compare target over an integer field of the keys. The
+// compares it builds always have exactly one target set.
+type NumericTarget struct {
+	key      string
+	rangeEnd string
+	target   etcdserverpb.Compare_CompareTarget
+}
+
+func ModRevision(key string) NumericTarget {
+	return NumericTarget{key: key, target: etcdserverpb.Compare_MOD}
+}
+
+func CreateRevision(key string) NumericTarget {
+	return NumericTarget{key: key, target: etcdserverpb.Compare_CREATE}
+}
+
+func Version(key string) NumericTarget {
+	return NumericTarget{key: key, target: etcdserverpb.Compare_VERSION}
+}
+
+func LeaseID(key string) NumericTarget {
+	return NumericTarget{key: key, target: etcdserverpb.Compare_LEASE}
+}
+
+func (target NumericTarget) WithRange(rangeEnd string) NumericTarget {
+	target.rangeEnd = rangeEnd
+	return target
+}
+
+func (target NumericTarget) WithPrefix() NumericTarget {
+	target.rangeEnd = GetPrefix(target.key)
+	return target
+}
+
+func (target NumericTarget) Equal(value int64) Compare {
+	return target.compare(value).Equal()
+}
+
+func (target NumericTarget) NotEqual(value int64) Compare {
+	return target.compare(value).NotEqual()
+}
+
+func (target NumericTarget) Greater(value int64) Compare {
+	return target.compare(value).Greater()
+}
+
+func (target NumericTarget) Less(value int64) Compare {
+	return target.compare(value).Less()
+}
+
+func (target NumericTarget) compare(value int64) Compare {
+	compare := Compare{Key: target.key, RangeEnd: target.rangeEnd}
+	switch target.target {
+	case etcdserverpb.Compare_MOD:
+		return compare.SetModRevision(value)
+	case etcdserverpb.Compare_CREATE:
+		return compare.SetCreateRevision(value)
+	case etcdserverpb.Compare_VERSION:
+		return compare.SetVersion(value)
 	default:
-		panic("expected one of compare target")
+		return compare.SetLease(value)
 	}
-	return result
+}
+
+// ValueTarget is a compare target over the values of the keys.
+type ValueTarget struct {
+	key      string
+	rangeEnd string
+}
+
+func Value(key string) ValueTarget {
+	return ValueTarget{key: key}
+}
+
+func (target ValueTarget) WithRange(rangeEnd string) ValueTarget {
+	target.rangeEnd = rangeEnd
+	return target
+}
+
+func (target ValueTarget) WithPrefix() ValueTarget {
+	target.rangeEnd = GetPrefix(target.key)
+	return target
+}
+
+func (target ValueTarget) Equal(value string) Compare {
+	return target.compare(value).Equal()
+}
+
+func (target ValueTarget) NotEqual(value string) Compare {
+	return target.compare(value).NotEqual()
+}
+
+func (target ValueTarget) Greater(value string) Compare {
+	return target.compare(value).Greater()
+}
+
+func (target ValueTarget) Less(value string) Compare {
+	return target.compare(value).Less()
+}
+
+func (target ValueTarget) compare(value string) Compare {
+	return Compare{Key: target.key, RangeEnd: target.rangeEnd}.SetValue(value)
 }
@@ -4,12 +4,13 @@ import (
 	"context"
 
 	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
 )
 
 type DeleteRequest struct {
-	Key      string
-	RangeEnd string
-	PrevKv   bool
+	Key      string `json:"key,omitempty"`
+	RangeEnd string `json:"range_end,omitempty"`
+	PrevKv   bool   `json:"prev_kv,omitempty"`
 }
 
 func (DeleteRequest) Request() {}
@@ -25,10 +26,21 @@ func serializeDeleteRequest(request *DeleteRequest) *etcdserverpb.DeleteRangeReq
 	}
 }
 
+func deserializeDeleteRequest(request *etcdserverpb.DeleteRangeRequest) *DeleteRequest {
+	if request == nil {
+		return nil
+	}
+	return &DeleteRequest{
+		Key:      string(request.Key),
+		RangeEnd: string(request.RangeEnd),
+		PrevKv:   request.PrevKv,
+	}
+}
+
 type DeleteResponse struct {
-	Revision int64
-	Deleted  int64
-	PrevKvs  []*KeyValue
+	Revision int64       `json:"revision,omitempty"`
+	Deleted  int64       `json:"deleted,omitempty"`
+	PrevKvs  []*KeyValue `json:"prev_kvs"`
 }
 
 func (DeleteResponse) Response() {}
@@ -46,7 +58,7 @@ func deserializeDeleteResponse(response *etcdserverpb.DeleteRangeResponse) *Dele
 		return nil
 	}
 	result := &DeleteResponse{
-		Revision: response.Header.Revision,
+		Revision: response.GetHeader().GetRevision(),
 		Deleted:  response.Deleted,
 		PrevKvs:  make([]*KeyValue, 0, len(response.PrevKvs)),
 	}
@@ -56,8 +68,26 @@ func deserializeDeleteResponse(response *etcdserverpb.DeleteRangeResponse) *Dele
 	return result
 }
 
-func Delete(ctx context.Context, client *Client, request *DeleteRequest) (*DeleteResponse, error) {
-	response, err := client.Delete(ctx, serializeDeleteRequest(request))
+func serializeDeleteResponse(response *DeleteResponse) *etcdserverpb.DeleteRangeResponse {
+	if response == nil {
+		return nil
+	}
+	result := &etcdserverpb.DeleteRangeResponse{
+		Header:  &etcdserverpb.ResponseHeader{Revision: response.Revision},
+		Deleted: response.Deleted,
+		PrevKvs: make([]*mvccpb.KeyValue, 0, len(response.PrevKvs)),
+	}
+	for _, prevKv := range response.PrevKvs {
+		result.PrevKvs = append(result.PrevKvs, serializeKeyValue(prevKv))
+	}
+	return result
+}
+
+func Delete(ctx context.Context, kv KV, request *DeleteRequest) (*DeleteResponse, error) {
+	if err := Validate(request); err != nil {
+		return nil, err
+	}
+	response, err := kv.Delete(ctx, serializeDeleteRequest(request))
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,366 @@
+package etcd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+)
+
+// Requests and responses are encoded to JSON as objects tagged with the
+// method, e.g. {"method":"Put","value":{"key":"k","value":"v"}}, so that the
+// ops of a TxnRequest and the responses of a TxnResponse can be decoded back
+// into their types. JSON keeps strings as UTF-8; keys and values holding
+// arbitrary bytes round trip through the protobuf encoding only.
+
+type taggedJSON struct {
+	Method string          `json:"method"`
+	Value  json.RawMessage `json:"value"`
+}
+
+// RequestMethod returns the name of the KV method serving the request.
+func RequestMethod(request Request) (string, error) {
+	switch request.(type) {
+	case *CompactRequest:
+		return MethodCompact, nil
+	case *DeleteRequest:
+		return MethodDelete, nil
+	case *PutRequest:
+		return MethodPut, nil
+	case *RangeRequest:
+		return MethodRange, nil
+	case *TxnRequest:
+		return MethodTxn, nil
+	default:
+		return "", fmt.Errorf("%w: %T", ErrUnknownRequest, request)
+	}
+}
+
+// ResponseMethod returns the name of the KV method the response comes from.
+func ResponseMethod(response Response) (string, error) {
+	switch response.(type) {
+	case *CompactResponse:
+		return MethodCompact, nil
+	case *DeleteResponse:
+		return MethodDelete, nil
+	case *PutResponse:
+		return MethodPut, nil
+	case *RangeResponse:
+		return MethodRange, nil
+	case *TxnResponse:
+		return MethodTxn, nil
+	default:
+		return "", fmt.Errorf("%w: %T", ErrUnknownResponse, response)
+	}
+}
+
+func MarshalRequest(request Request) ([]byte, error) {
+	method, err := RequestMethod(request)
+	if err != nil {
+		return nil, err
+	}
+	return marshalTagged(method, request)
+}
+
+func UnmarshalRequest(data []byte) (Request, error) {
+	var tagged taggedJSON
+	if err := json.Unmarshal(data, &tagged); err != nil {
+		return nil, err
+	}
+	var request Request
+	switch tagged.Method {
+	case MethodCompact:
+		request = &CompactRequest{}
+	case MethodDelete:
+		request = &DeleteRequest{}
+	case MethodPut:
+		request = &PutRequest{}
+	case MethodRange:
+		request = &RangeRequest{}
+	case MethodTxn:
+		request = &TxnRequest{}
+	default:
+		return nil, fmt.Errorf("%w: method %q", ErrUnknownRequest, tagged.Method)
+	}
+	if err := json.Unmarshal(tagged.Value, request); err != nil {
+		return nil, err
+	}
+	return request, nil
+}
+
+func MarshalResponse(response Response) ([]byte, error) {
+	method, err := ResponseMethod(response)
+	if err != nil {
+		return nil, err
+	}
+	return marshalTagged(method, response)
+}
+
+func UnmarshalResponse(data []byte) (Response, error) {
+	var tagged taggedJSON
+	if err := json.Unmarshal(data, &tagged); err != nil {
+		return nil, err
+	}
+	var response Response
+	switch tagged.Method {
+	case MethodCompact:
+		response = &CompactResponse{}
+	case MethodDelete:
+		response = &DeleteResponse{}
+	case MethodPut:
+		response = &PutResponse{}
+	case MethodRange:
+		response = &RangeResponse{}
+	case MethodTxn:
+		response = &TxnResponse{}
+	default:
+		return nil, fmt.Errorf("%w: method %q", ErrUnknownResponse, tagged.Method)
+	}
+	if err := json.Unmarshal(tagged.Value, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+func marshalTagged(method string, value any) ([]byte, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(taggedJSON{Method: method, Value: data})
+}
+
+type txnRequestJSON struct {
+	Compare []Compare         `json:"compare"`
+	Success []json.RawMessage `json:"success"`
+	Failure []json.RawMessage `json:"failure"`
+}
+
+func (request TxnRequest) MarshalJSON() ([]byte, error) {
+	result := txnRequestJSON{Compare: request.Compare}
+	var err error
+	if result.Success, err = marshalRequests(request.Success); err != nil {
+		return nil, err
+	}
+	if result.Failure, err = marshalRequests(request.Failure); err != nil {
+		return nil, err
+	}
+	return json.Marshal(result)
+}
+
+func (request *TxnRequest) UnmarshalJSON(data []byte) error {
+	var decoded txnRequestJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	request.Compare = decoded.Compare
+	var err error
+	if request.Success, err = unmarshalRequests(decoded.Success); err != nil {
+		return err
+	}
+	request.Failure, err = unmarshalRequests(decoded.Failure)
+	return err
+}
+
+func marshalRequests(requests []Request) ([]json.RawMessage, error) {
+	if requests == nil {
+		return nil, nil
+	}
+	result := make([]json.RawMessage, 0, len(requests))
+	for _, request := range requests {
+		data, err := MarshalRequest(request)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, data)
+	}
+	return result, nil
+}
+
+func unmarshalRequests(data []json.RawMessage) ([]Request, error) {
+	if data == nil {
+		return nil, nil
+	}
+	result := make([]Request, 0, len(data))
+	for _, item := range data {
+		request, err := UnmarshalRequest(item)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, request)
+	}
+	return result, nil
+}
+
+type txnResponseJSON struct {
+	Revision  int64             `json:"revision,omitempty"`
+	Succeeded bool              `json:"succeeded,omitempty"`
+	Responses []json.RawMessage `json:"responses"`
+}
+
+func (response TxnResponse) MarshalJSON() ([]byte, error) {
+	result := txnResponseJSON{Revision: response.Revision, Succeeded: response.Succeeded}
+	if response.Responses != nil {
+		result.Responses = make([]json.RawMessage, 0, len(response.Responses))
+	}
+	for _, op := range response.Responses {
+		data, err := MarshalResponse(op)
+		if err != nil {
+			return nil, err
+		}
+		result.Responses = append(result.Responses, data)
+	}
+	return json.Marshal(result)
+}
+
+func (response *TxnResponse) UnmarshalJSON(data []byte) error {
+	var decoded txnResponseJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	response.Revision, response.Succeeded, response.Responses = decoded.Revision, decoded.Succeeded, nil
+	if decoded.Responses != nil {
+		response.Responses = make([]Response, 0, len(decoded.Responses))
+	}
+	for _, item := range decoded.Responses {
+		op, err := UnmarshalResponse(item)
+		if err != nil {
+			return err
+		}
+		response.Responses = append(response.Responses, op)
+	}
+	return nil
+}
+
+// MarshalRequestProto encodes a request as the etcdserverpb message of its
+// method.
+func MarshalRequestProto(request Request) ([]byte, error) {
+	switch r := request.(type) {
+	case *CompactRequest:
+		return marshalProto(serializeCompactRequest(r))
+	case *DeleteRequest:
+		return marshalProto(serializeDeleteRequest(r))
+	case *PutRequest:
+		return marshalProto(serializePutRequest(r))
+	case *RangeRequest:
+		return marshalProto(serializeRangeRequest(r))
+	case *TxnRequest:
+		txn, err := serializeTxnRequest(r)
+		if err != nil {
+			return nil, err
+		}
+		return marshalProto(txn)
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnknownRequest, request)
+	}
+}
+
+// UnmarshalRequestProto decodes the etcdserverpb request message of a method.
+func UnmarshalRequestProto(method string, data []byte) (Request, error) {
+	switch method {
+	case MethodCompact:
+		var request etcdserverpb.CompactionRequest
+		if err := request.Unmarshal(data); err != nil {
+			return nil, err
+		}
+		return deserializeCompactRequest(&request), nil
+	case MethodDelete:
+		var request etcdserverpb.DeleteRangeRequest
+		if err := request.Unmarshal(data); err != nil {
+			return nil, err
+		}
+		return deserializeDeleteRequest(&request), nil
+	case MethodPut:
+		var request etcdserverpb.PutRequest
+		if err := request.Unmarshal(data); err != nil {
+			return nil, err
+		}
+		return deserializePutRequest(&request), nil
+	case MethodRange:
+		var request etcdserverpb.RangeRequest
+		if err := request.Unmarshal(data); err != nil {
+			return nil, err
+		}
+		return deserializeRangeRequest(&request), nil
+	case MethodTxn:
+		var request etcdserverpb.TxnRequest
+		if err := request.Unmarshal(data); err != nil {
+			return nil, err
+		}
+		return deserializeTxnRequest(&request)
+	default:
+		return nil, fmt.Errorf("%w: method %q", ErrUnknownRequest, method)
+	}
+}
+
+// MarshalResponseProto encodes a response as the etcdserverpb message of its
+// method. Only the revision of the response header is kept.
+func MarshalResponseProto(response Response) ([]byte, error) {
+	switch r := response.(type) {
+	case *CompactResponse:
+		return marshalProto(serializeCompactResponse(r))
+	case *DeleteResponse:
+		return marshalProto(serializeDeleteResponse(r))
+	case *PutResponse:
+		return marshalProto(serializePutResponse(r))
+	case *RangeResponse:
+		return marshalProto(serializeRangeResponse(r))
+	case *TxnResponse:
+		txn, err := serializeTxnResponse(r)
+		if err != nil {
+			return nil, err
+		}
+		return marshalProto(txn)
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnknownResponse, response)
+	}
+}
+
+// UnmarshalResponseProto decodes the etcdserverpb response message of a
+// method.
+func UnmarshalResponseProto(method string, data []byte) (Response, error) {
+	switch method {
+	case MethodCompact:
+		var response etcdserverpb.CompactionResponse
+		if err := response.Unmarshal(data); err != nil {
+			return nil, err
+		}
+		return deserializeCompactResponse(&response), nil
+	case MethodDelete:
+		var response etcdserverpb.DeleteRangeResponse
+		if err := response.Unmarshal(data); err != nil {
+			return nil, err
+		}
+		return deserializeDeleteResponse(&response), nil
+	case MethodPut:
+		var response etcdserverpb.PutResponse
+		if err := response.Unmarshal(data); err != nil {
+			return nil, err
+		}
+		return deserializePutResponse(&response), nil
+	case MethodRange:
+		var response etcdserverpb.RangeResponse
+		if err := response.Unmarshal(data); err != nil {
+			return nil, err
+		}
+		return deserializeRangeResponse(&response), nil
+	case MethodTxn:
+		var response etcdserverpb.TxnResponse
+		if err := response.Unmarshal(data); err != nil {
+			return nil, err
+		}
+		return deserializeTxnResponse(&response)
+	default:
+		return nil, fmt.Errorf("%w: method %q", ErrUnknownResponse, method)
+	}
+}
+
+func marshalProto[T any, M interface {
+	*T
+	Marshal() ([]byte, error)
+}](message M) ([]byte, error) {
+	if message == nil {
+		return nil, fmt.Errorf("etcd: cannot encode nil %T", message)
+	}
+	return message.Marshal()
+}
@@ -0,0 +1,369 @@
+package etcd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+func WithUnaryInterceptors(interceptors ...grpc.UnaryClientInterceptor) Option {
+	return func(o *options) {
+		o.unaryInterceptors = append(o.unaryInterceptors, interceptors...)
+	}
+}
+
+func WithStreamInterceptors(interceptors ...grpc.StreamClientInterceptor) Option {
+	return func(o *options) {
+		o.streamInterceptors = append(o.streamInterceptors, interceptors...)
+	}
+}
+
+// LoggingUnaryInterceptor logs every request with its key range, revisions
+// and outcome. Values are never logged, only their sizes.
+func LoggingUnaryInterceptor(logger *slog.Logger) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		attrs := append(requestAttrs(req), slog.Duration("duration", time.Since(start)))
+		if err == nil {
+			attrs = append(attrs, responseAttrs(reply)...)
+		}
+		logCall(ctx, logger, method, err, attrs)
+		return err
+	}
+}
+
+func LoggingStreamInterceptor(logger *slog.Logger) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		start := time.Now()
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			logCall(ctx, logger, method, err, []slog.Attr{slog.Duration("duration", time.Since(start))})
+			return nil, err
+		}
+		return newMonitoredStream(stream, func(err error, sent, received int) {
+			logCall(ctx, logger, method, err, []slog.Attr{
+				slog.Duration("duration", time.Since(start)),
+				slog.Int("sent", sent),
+				slog.Int("received", received),
+			})
+		}), nil
+	}
+}
+
+func logCall(ctx context.Context, logger *slog.Logger, method string, err error, attrs []slog.Attr) {
+	attrs = append(attrs, slog.String("method", method), slog.String("code", status.Code(err).String()))
+	if err != nil {
+		logger.LogAttrs(ctx, slog.LevelWarn, "etcd request failed", append(attrs, slog.String("error", err.Error()))...)
+		return
+	}
+	logger.LogAttrs(ctx, slog.LevelDebug, "etcd request", attrs...)
+}
+
+// TracingUnaryInterceptor wraps every request into a client span carrying the
+// requested key range and revisions, and the revision of the response.
+func TracingUnaryInterceptor(tracer trace.Tracer) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, span := tracer.Start(ctx, strings.TrimPrefix(method, "/"), trace.WithSpanKind(trace.SpanKindClient))
+		defer span.End()
+		span.SetAttributes(spanAttrs(requestAttrs(req))...)
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(otelcodes.Error, status.Code(err).String())
+			return err
+		}
+		span.SetAttributes(spanAttrs(responseAttrs(reply))...)
+		return nil
+	}
+}
+
+func TracingStreamInterceptor(tracer trace.Tracer) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, span := tracer.Start(ctx, strings.TrimPrefix(method, "/"), trace.WithSpanKind(trace.SpanKindClient))
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(otelcodes.Error, status.Code(err).String())
+			span.End()
+			return nil, err
+		}
+		return newMonitoredStream(stream, func(err error, sent, received int) {
+			span.SetAttributes(attribute.Int("etcd.sent", sent), attribute.Int("etcd.received", received))
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(otelcodes.Error, status.Code(err).String())
+			}
+			span.End()
+		}), nil
+	}
+}
+
+func spanAttrs(attrs []slog.Attr) []attribute.KeyValue {
+	result := make([]attribute.KeyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		key := "etcd." + attr.Key
+		switch attr.Value.Kind() {
+		case slog.KindBool:
+			result = append(result, attribute.Bool(key, attr.Value.Bool()))
+		case slog.KindInt64:
+			result = append(result, attribute.Int64(key, attr.Value.Int64()))
+		default:
+			result = append(result, attribute.String(key, attr.Value.String()))
+		}
+	}
+	return result
+}
+
+func requestAttrs(request any) []slog.Attr {
+	switch r := request.(type) {
+	case *etcdserverpb.RangeRequest:
+		return []slog.Attr{
+			slog.String("key", string(r.Key)),
+			slog.String("range_end", string(r.RangeEnd)),
+			slog.Int64("revision", r.Revision),
+			slog.Int64("limit", r.Limit),
+		}
+	case *etcdserverpb.PutRequest:
+		return []slog.Attr{
+			slog.String("key", string(r.Key)),
+			slog.Int("value_size", len(r.Value)),
+		}
+	case *etcdserverpb.DeleteRangeRequest:
+		return []slog.Attr{
+			slog.String("key", string(r.Key)),
+			slog.String("range_end", string(r.RangeEnd)),
+		}
+	case *etcdserverpb.TxnRequest:
+		return []slog.Attr{
+			slog.Int("compare", len(r.Compare)),
+			slog.Int("success", len(r.Success)),
+			slog.Int("failure", len(r.Failure)),
+		}
+	case *etcdserverpb.CompactionRequest:
+		return []slog.Attr{
+			slog.Int64("revision", r.Revision),
+			slog.Bool("physical", r.Physical),
+		}
+	default:
+		return nil
+	}
+}
+
+func responseAttrs(response any) []slog.Attr {
+	var attrs []slog.Attr
+	if r, ok := response.(interface {
+		GetHeader() *etcdserverpb.ResponseHeader
+	}); ok {
+		attrs = append(attrs, slog.Int64("response_revision", r.GetHeader().GetRevision()))
+	}
+	switch r := response.(type) {
+	case *etcdserverpb.RangeResponse:
+		attrs = append(attrs, slog.Int64("count", r.Count), slog.Bool("more", r.More))
+	case *etcdserverpb.DeleteRangeResponse:
+		attrs = append(attrs, slog.Int64("deleted", r.Deleted))
+	case *etcdserverpb.TxnResponse:
+		attrs = append(attrs, slog.Bool("succeeded", r.Succeeded))
+	}
+	return attrs
+}
+
+// monitoredStream reports the outcome of a stream once, when it ends.
+type monitoredStream struct {
+	grpc.ClientStream
+	once     sync.Once
+	mu       sync.Mutex
+	sent     int
+	received int
+	done     func(err error, sent, received int)
+}
+
+func newMonitoredStream(stream grpc.ClientStream, done func(err error, sent, received int)) *monitoredStream {
+	return &monitoredStream{ClientStream: stream, done: done}
+}
+
+func (s *monitoredStream) SendMsg(m any) error {
+	err := s.ClientStream.SendMsg(m)
+	if err != nil {
+		s.finish(err)
+	} else {
+		s.mu.Lock()
+		s.sent++
+		s.mu.Unlock()
+	}
+	return err
+}
+
+func (s *monitoredStream) RecvMsg(m any) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		s.finish(err)
+	} else {
+		s.mu.Lock()
+		s.received++
+		s.mu.Unlock()
+	}
+	return err
+}
+
+func (s *monitoredStream) finish(err error) {
+	if errors.Is(err, io.EOF) {
+		err = nil
+	}
+	s.once.Do(func() {
+		s.mu.Lock()
+		sent, received := s.sent, s.received
+		s.mu.Unlock()
+		s.done(err, sent, received)
+	})
+}
+
+var defaultBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metrics counts handled requests per method and code and tracks their
+// latency, exposed in the Prometheus text format under the same names as
+// go-grpc-prometheus client metrics.
+type Metrics struct {
+	mu        sync.Mutex
+	handled   map[metricLabels]uint64
+	latencies map[metricLabels]*histogram
+}
+
+type metricLabels struct {
+	kind    string
+	service string
+	method  string
+	code    string
+}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		handled:   make(map[metricLabels]uint64),
+		latencies: make(map[metricLabels]*histogram),
+	}
+}
+
+func (m *Metrics) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		start := time.Now()
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		m.observe("unary", method, err, time.Since(start))
+		return err
+	}
+}
+
+func (m *Metrics) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		kind := streamKind(desc)
+		start := time.Now()
+		stream, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			m.observe(kind, method, err, time.Since(start))
+			return nil, err
+		}
+		return newMonitoredStream(stream, func(err error, _, _ int) {
+			m.observe(kind, method, err, time.Since(start))
+		}), nil
+	}
+}
+
+func streamKind(desc *grpc.StreamDesc) string {
+	switch {
+	case desc.ClientStreams && desc.ServerStreams:
+		return "bidi_stream"
+	case desc.ClientStreams:
+		return "client_stream"
+	default:
+		return "server_stream"
+	}
+}
+
+func (m *Metrics) observe(kind, method string, err error, duration time.Duration) {
+	service, name, _ := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+	labels := metricLabels{kind: kind, service: service, method: name}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h, ok := m.latencies[labels]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(defaultBuckets))}
+		m.latencies[labels] = h
+	}
+	seconds := duration.Seconds()
+	for i, bucket := range defaultBuckets {
+		if seconds <= bucket {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += seconds
+
+	labels.code = status.Code(err).String()
+	m.handled[labels]++
+}
+
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var b strings.Builder
+	b.WriteString("# HELP grpc_client_handled_total Total number of RPCs completed by the client, regardless of success or failure.\n")
+	b.WriteString("# TYPE grpc_client_handled_total counter\n")
+	for _, labels := range sortedLabels(m.handled) {
+		fmt.Fprintf(&b, "grpc_client_handled_total{%s,grpc_code=%q} %d\n", labels, labels.code, m.handled[labels])
+	}
+	b.WriteString("# HELP grpc_client_handling_seconds Histogram of response latency (seconds) of the gRPC until it is finished by the application.\n")
+	b.WriteString("# TYPE grpc_client_handling_seconds histogram\n")
+	for _, labels := range sortedLabels(m.latencies) {
+		h := m.latencies[labels]
+		for i, bucket := range defaultBuckets {
+			fmt.Fprintf(&b, "grpc_client_handling_seconds_bucket{%s,le=\"%g\"} %d\n", labels, bucket, h.counts[i])
+		}
+		fmt.Fprintf(&b, "grpc_client_handling_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+		fmt.Fprintf(&b, "grpc_client_handling_seconds_sum{%s} %g\n", labels, h.sum)
+		fmt.Fprintf(&b, "grpc_client_handling_seconds_count{%s} %d\n", labels, h.count)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	m.WritePrometheus(w)
+}
+
+func (labels metricLabels) String() string {
+	return fmt.Sprintf("grpc_type=%q,grpc_service=%q,grpc_method=%q", labels.kind, labels.service, labels.method)
+}
+
+func sortedLabels[V any](m map[metricLabels]V) []metricLabels {
+	result := make([]metricLabels, 0, len(m))
+	for labels := range m {
+		result = append(result, labels)
+	}
+	slices.SortFunc(result, func(a, b metricLabels) int {
+		return strings.Compare(a.String()+a.code, b.String()+b.code)
+	})
+	return result
+}
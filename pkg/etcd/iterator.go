@@ -0,0 +1,129 @@
+package etcd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+)
+
+// CompactedError is returned by RangeIterator when the revision it is pinned
+// to gets compacted in the middle of the scan.
+type CompactedError struct {
+	Revision int64
+}
+
+func (err *CompactedError) Error() string {
+	return fmt.Sprintf("etcd: revision %d of range scan has been compacted", err.Revision)
+}
+
+func (err *CompactedError) Unwrap() error {
+	return rpctypes.ErrGRPCCompacted
+}
+
+// RangeIterator walks the keys of a RangeRequest in pages of limited size.
+// All pages are read at the revision of the first one, so the scan observes
+// a consistent snapshot regardless of concurrent writes.
+//
+// Only Key, RangeEnd, Revision, KeysOnly and SortOrder of the request are
+// taken into account; keys are always ordered by key.
+type RangeIterator struct {
+	kv       KV
+	request  RangeRequest
+	pageSize int64
+
+	page  []*KeyValue
+	index int
+	more  bool
+	err   error
+}
+
+func NewRangeIterator(kv KV, request *RangeRequest, pageSize int64) *RangeIterator {
+	it := &RangeIterator{
+		kv:       kv,
+		request:  *request,
+		pageSize: pageSize,
+		more:     true,
+	}
+	switch {
+	case pageSize <= 0:
+		it.err = fmt.Errorf("etcd: page size must be positive, got %d", pageSize)
+	case request.SortTarget != etcdserverpb.RangeRequest_KEY:
+		it.err = fmt.Errorf("etcd: range iterator orders by key only, got %s", request.SortTarget)
+	}
+	return it
+}
+
+// Next advances the iterator to the next key, fetching the next page when the
+// current one is exhausted. It returns false when the scan is over or failed.
+func (it *RangeIterator) Next(ctx context.Context) bool {
+	if it.err != nil {
+		return false
+	}
+	if it.index+1 < len(it.page) {
+		it.index++
+		return true
+	}
+	for it.more {
+		if err := it.fetch(ctx); err != nil {
+			it.err = err
+			return false
+		}
+		if len(it.page) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (it *RangeIterator) KeyValue() *KeyValue {
+	if it.index >= len(it.page) {
+		return nil
+	}
+	return it.page[it.index]
+}
+
+// Revision is the revision the scan is pinned to, known after the first call
+// of Next.
+func (it *RangeIterator) Revision() int64 {
+	return it.request.Revision
+}
+
+func (it *RangeIterator) Err() error {
+	return it.err
+}
+
+func (it *RangeIterator) fetch(ctx context.Context) error {
+	request := &RangeRequest{
+		Key:       it.request.Key,
+		RangeEnd:  it.request.RangeEnd,
+		Limit:     it.pageSize,
+		Revision:  it.request.Revision,
+		KeysOnly:  it.request.KeysOnly,
+		SortOrder: it.request.SortOrder,
+	}
+	response, err := Range(ctx, it.kv, request)
+	if errors.Is(err, rpctypes.ErrGRPCCompacted) {
+		return &CompactedError{Revision: it.request.Revision}
+	} else if err != nil {
+		return err
+	}
+	if it.request.Revision == 0 {
+		it.request.Revision = response.Revision
+	}
+
+	it.page, it.index = response.Kvs, 0
+	it.more = response.More && len(response.Kvs) != 0 && len(it.request.RangeEnd) != 0
+	if !it.more {
+		return nil
+	}
+	last := response.Kvs[len(response.Kvs)-1].Key
+	if it.request.SortOrder == etcdserverpb.RangeRequest_DESCEND {
+		it.request.RangeEnd = last
+	} else {
+		it.request.Key = last + "\x00"
+	}
+	return nil
+}
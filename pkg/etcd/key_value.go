@@ -5,11 +5,12 @@ import (
 )
 
 type KeyValue struct {
-	Key            string
-	ModRevision    int64
-	CreateRevision int64
-	Version        int64
-	Value          string
+	Key            string `json:"key,omitempty"`
+	ModRevision    int64  `json:"mod_revision,omitempty"`
+	CreateRevision int64  `json:"create_revision,omitempty"`
+	Version        int64  `json:"version,omitempty"`
+	Value          string `json:"value,omitempty"`
+	Lease          int64  `json:"lease,omitempty"`
 }
 
 func deserializeKeyValue(kv *mvccpb.KeyValue) *KeyValue {
@@ -22,5 +23,20 @@ func deserializeKeyValue(kv *mvccpb.KeyValue) *KeyValue {
 		CreateRevision: kv.CreateRevision,
 		Version:        kv.Version,
 		Value:          string(kv.Value),
+		Lease:          kv.Lease,
+	}
+}
+
+func serializeKeyValue(kv *KeyValue) *mvccpb.KeyValue {
+	if kv == nil {
+		return nil
+	}
+	return &mvccpb.KeyValue{
+		Key:            []byte(kv.Key),
+		ModRevision:    kv.ModRevision,
+		CreateRevision: kv.CreateRevision,
+		Version:        kv.Version,
+		Value:          []byte(kv.Value),
+		Lease:          kv.Lease,
 	}
 }
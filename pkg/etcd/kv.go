@@ -0,0 +1,65 @@
+package etcd
+
+import (
+	"context"
+	"fmt"
+
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+)
+
+type Request interface {
+	Request()
+}
+
+type Response interface {
+	Response()
+	GetRevision() int64
+	IsWrite() bool
+}
+
+// KV is the transport the request helpers run on. It is implemented by the
+// gRPC Client, by MemoryKV and by the Recorder and Replayer wrappers.
+type KV interface {
+	Range(ctx context.Context, request *etcdserverpb.RangeRequest) (*etcdserverpb.RangeResponse, error)
+	Put(ctx context.Context, request *etcdserverpb.PutRequest) (*etcdserverpb.PutResponse, error)
+	Delete(ctx context.Context, request *etcdserverpb.DeleteRangeRequest) (*etcdserverpb.DeleteRangeResponse, error)
+	Txn(ctx context.Context, request *etcdserverpb.TxnRequest) (*etcdserverpb.TxnResponse, error)
+	Compact(ctx context.Context, request *etcdserverpb.CompactionRequest) (*etcdserverpb.CompactionResponse, error)
+}
+
+type Lease interface {
+	LeaseGrant(ctx context.Context, request *etcdserverpb.LeaseGrantRequest) (*etcdserverpb.LeaseGrantResponse, error)
+	LeaseRevoke(ctx context.Context, request *etcdserverpb.LeaseRevokeRequest) (*etcdserverpb.LeaseRevokeResponse, error)
+	LeaseTimeToLive(ctx context.Context, request *etcdserverpb.LeaseTimeToLiveRequest) (*etcdserverpb.LeaseTimeToLiveResponse, error)
+	LeaseKeepAlive(ctx context.Context) (etcdserverpb.Lease_LeaseKeepAliveClient, error)
+}
+
+type Watcher interface {
+	Watch(ctx context.Context) (etcdserverpb.Watch_WatchClient, error)
+}
+
+var (
+	_ KV      = (*Client)(nil)
+	_ Lease   = (*Client)(nil)
+	_ Watcher = (*Client)(nil)
+	_ KV      = (*MemoryKV)(nil)
+	_ KV      = (*Recorder)(nil)
+	_ KV      = (*Replayer)(nil)
+)
+
+func Do(ctx context.Context, kv KV, request Request) (Response, error) {
+	switch r := request.(type) {
+	case *CompactRequest:
+		return Compact(ctx, kv, r)
+	case *DeleteRequest:
+		return Delete(ctx, kv, r)
+	case *PutRequest:
+		return Put(ctx, kv, r)
+	case *RangeRequest:
+		return Range(ctx, kv, r)
+	case *TxnRequest:
+		return Txn(ctx, kv, r)
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnknownRequest, request)
+	}
+}
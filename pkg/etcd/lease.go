@@ -0,0 +1,181 @@
+package etcd
+
+import (
+	"context"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+)
+
+type LeaseGrantRequest struct {
+	TTL int64 `json:"ttl,omitempty"`
+	ID  int64 `json:"id,omitempty"`
+}
+
+func serializeLeaseGrantRequest(request *LeaseGrantRequest) *etcdserverpb.LeaseGrantRequest {
+	if request == nil {
+		return nil
+	}
+	return &etcdserverpb.LeaseGrantRequest{
+		TTL: request.TTL,
+		ID:  request.ID,
+	}
+}
+
+type LeaseGrantResponse struct {
+	Revision int64 `json:"revision,omitempty"`
+	ID       int64 `json:"id,omitempty"`
+	TTL      int64 `json:"ttl,omitempty"`
+}
+
+func deserializeLeaseGrantResponse(response *etcdserverpb.LeaseGrantResponse) *LeaseGrantResponse {
+	if response == nil {
+		return nil
+	}
+	return &LeaseGrantResponse{
+		Revision: response.GetHeader().GetRevision(),
+		ID:       response.ID,
+		TTL:      response.TTL,
+	}
+}
+
+func LeaseGrant(ctx context.Context, lease Lease, request *LeaseGrantRequest) (*LeaseGrantResponse, error) {
+	response, err := lease.LeaseGrant(ctx, serializeLeaseGrantRequest(request))
+	if err != nil {
+		return nil, err
+	}
+	return deserializeLeaseGrantResponse(response), nil
+}
+
+type LeaseRevokeRequest struct {
+	ID int64 `json:"id,omitempty"`
+}
+
+func serializeLeaseRevokeRequest(request *LeaseRevokeRequest) *etcdserverpb.LeaseRevokeRequest {
+	if request == nil {
+		return nil
+	}
+	return &etcdserverpb.LeaseRevokeRequest{
+		ID: request.ID,
+	}
+}
+
+type LeaseRevokeResponse struct {
+	Revision int64 `json:"revision,omitempty"`
+}
+
+func deserializeLeaseRevokeResponse(response *etcdserverpb.LeaseRevokeResponse) *LeaseRevokeResponse {
+	if response == nil {
+		return nil
+	}
+	return &LeaseRevokeResponse{
+		Revision: response.GetHeader().GetRevision(),
+	}
+}
+
+func LeaseRevoke(ctx context.Context, lease Lease, request *LeaseRevokeRequest) (*LeaseRevokeResponse, error) {
+	response, err := lease.LeaseRevoke(ctx, serializeLeaseRevokeRequest(request))
+	if err != nil {
+		return nil, err
+	}
+	return deserializeLeaseRevokeResponse(response), nil
+}
+
+type LeaseTimeToLiveRequest struct {
+	ID   int64 `json:"id,omitempty"`
+	Keys bool  `json:"keys,omitempty"`
+}
+
+func serializeLeaseTimeToLiveRequest(request *LeaseTimeToLiveRequest) *etcdserverpb.LeaseTimeToLiveRequest {
+	if request == nil {
+		return nil
+	}
+	return &etcdserverpb.LeaseTimeToLiveRequest{
+		ID:   request.ID,
+		Keys: request.Keys,
+	}
+}
+
+type LeaseTimeToLiveResponse struct {
+	Revision   int64    `json:"revision,omitempty"`
+	ID         int64    `json:"id,omitempty"`
+	TTL        int64    `json:"ttl,omitempty"`
+	GrantedTTL int64    `json:"granted_ttl,omitempty"`
+	Keys       []string `json:"keys"`
+}
+
+func deserializeLeaseTimeToLiveResponse(response *etcdserverpb.LeaseTimeToLiveResponse) *LeaseTimeToLiveResponse {
+	if response == nil {
+		return nil
+	}
+	var keys []string
+	for _, key := range response.Keys {
+		keys = append(keys, string(key))
+	}
+	return &LeaseTimeToLiveResponse{
+		Revision:   response.GetHeader().GetRevision(),
+		ID:         response.ID,
+		TTL:        response.TTL,
+		GrantedTTL: response.GrantedTTL,
+		Keys:       keys,
+	}
+}
+
+func LeaseTimeToLive(ctx context.Context, lease Lease, request *LeaseTimeToLiveRequest) (*LeaseTimeToLiveResponse, error) {
+	response, err := lease.LeaseTimeToLive(ctx, serializeLeaseTimeToLiveRequest(request))
+	if err != nil {
+		return nil, err
+	}
+	return deserializeLeaseTimeToLiveResponse(response), nil
+}
+
+type LeaseKeepAliveResponse struct {
+	Revision int64 `json:"revision,omitempty"`
+	ID       int64 `json:"id,omitempty"`
+	TTL      int64 `json:"ttl,omitempty"`
+}
+
+func deserializeLeaseKeepAliveResponse(response *etcdserverpb.LeaseKeepAliveResponse) *LeaseKeepAliveResponse {
+	if response == nil {
+		return nil
+	}
+	return &LeaseKeepAliveResponse{
+		Revision: response.GetHeader().GetRevision(),
+		ID:       response.ID,
+		TTL:      response.TTL,
+	}
+}
+
+// LeaseKeepAlive refreshes the lease every third of its TTL until ctx is done
+// or the lease expires. The returned channel receives the refreshes and is
+// closed when keeping alive stops; a refresh is dropped while the previous
+// one is not received yet, so a slow reader does not stall keeping alive.
+func LeaseKeepAlive(ctx context.Context, lease Lease, id int64) (<-chan *LeaseKeepAliveResponse, error) {
+	stream, err := lease.LeaseKeepAlive(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ch := make(chan *LeaseKeepAliveResponse, 1)
+	go func() {
+		defer close(ch)
+		for {
+			if err := stream.Send(&etcdserverpb.LeaseKeepAliveRequest{ID: id}); err != nil {
+				return
+			}
+			response, err := stream.Recv()
+			if err != nil || response.TTL <= 0 {
+				return
+			}
+			select {
+			case ch <- deserializeLeaseKeepAliveResponse(response):
+			default:
+			}
+			select {
+			case <-time.After(time.Duration(response.TTL) * time.Second / 3):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
@@ -0,0 +1,515 @@
+package etcd
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"slices"
+	"sort"
+	"sync"
+
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+)
+
+// MemoryKV is an in-process KV following etcd MVCC semantics: every write
+// creates a new revision, past revisions stay readable until compacted.
+type MemoryKV struct {
+	mu        sync.Mutex
+	revision  int64
+	compacted int64
+	// history holds every version of a key ordered by mod revision, a
+	// deletion is recorded as a tombstone with zero version.
+	history map[string][]*mvccpb.KeyValue
+}
+
+func NewMemoryKV() *MemoryKV {
+	return &MemoryKV{
+		revision: 1,
+		history:  make(map[string][]*mvccpb.KeyValue),
+	}
+}
+
+func (m *MemoryKV) Range(_ context.Context, request *etcdserverpb.RangeRequest) (*etcdserverpb.RangeResponse, error) {
+	if len(request.Key) == 0 {
+		return nil, rpctypes.ErrGRPCEmptyKey
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.txn().rangeKeys(request)
+}
+
+func (m *MemoryKV) Put(_ context.Context, request *etcdserverpb.PutRequest) (*etcdserverpb.PutResponse, error) {
+	if err := checkPutRequest(request); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	txn := m.txn()
+	response, err := txn.put(request)
+	if err != nil {
+		return nil, err
+	}
+	txn.commit()
+	return response, nil
+}
+
+func (m *MemoryKV) Delete(_ context.Context, request *etcdserverpb.DeleteRangeRequest) (*etcdserverpb.DeleteRangeResponse, error) {
+	if len(request.Key) == 0 {
+		return nil, rpctypes.ErrGRPCEmptyKey
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	txn := m.txn()
+	response := txn.deleteRange(request)
+	txn.commit()
+	return response, nil
+}
+
+func (m *MemoryKV) Txn(_ context.Context, request *etcdserverpb.TxnRequest) (*etcdserverpb.TxnResponse, error) {
+	if err := checkTxnRequest(request); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	txn := m.txn()
+	response, err := txn.txn(request)
+	if err != nil {
+		txn.rollback()
+		return nil, err
+	}
+	txn.commit()
+	response.Header.Revision = txn.rev()
+	return response, nil
+}
+
+func (m *MemoryKV) Compact(_ context.Context, request *etcdserverpb.CompactionRequest) (*etcdserverpb.CompactionResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if request.Revision <= m.compacted {
+		return nil, rpctypes.ErrGRPCCompacted
+	}
+	if request.Revision > m.revision {
+		return nil, rpctypes.ErrGRPCFutureRev
+	}
+	m.compacted = request.Revision
+	for key, versions := range m.history {
+		i := sort.Search(len(versions), func(i int) bool { return versions[i].ModRevision > m.compacted })
+		if i == 0 {
+			continue
+		}
+		if versions[i-1].Version != 0 {
+			i--
+		}
+		if i == len(versions) {
+			delete(m.history, key)
+		} else {
+			m.history[key] = versions[i:]
+		}
+	}
+	return &etcdserverpb.CompactionResponse{Header: &etcdserverpb.ResponseHeader{Revision: m.revision}}, nil
+}
+
+func (m *MemoryKV) txn() *memoryTxn {
+	return &memoryTxn{kv: m, begin: m.revision}
+}
+
+// memoryTxn applies all writes at revision begin+1, reads inside the txn
+// observe its own writes like they do in etcd.
+type memoryTxn struct {
+	kv      *MemoryKV
+	begin   int64
+	written []string
+}
+
+func (txn *memoryTxn) rev() int64 {
+	if len(txn.written) != 0 {
+		return txn.begin + 1
+	}
+	return txn.begin
+}
+
+func (txn *memoryTxn) commit() {
+	txn.kv.revision = txn.rev()
+}
+
+func (txn *memoryTxn) rollback() {
+	for _, key := range txn.written {
+		versions := txn.kv.history[key]
+		if len(versions) == 1 {
+			delete(txn.kv.history, key)
+		} else {
+			txn.kv.history[key] = versions[:len(versions)-1]
+		}
+	}
+	txn.written = nil
+}
+
+func (txn *memoryTxn) get(key string, revision int64) *mvccpb.KeyValue {
+	versions := txn.kv.history[key]
+	i := sort.Search(len(versions), func(i int) bool { return versions[i].ModRevision > revision })
+	if i == 0 || versions[i-1].Version == 0 {
+		return nil
+	}
+	return versions[i-1]
+}
+
+func (txn *memoryTxn) keys(key, rangeEnd []byte, revision int64) []*mvccpb.KeyValue {
+	var result []*mvccpb.KeyValue
+	if len(rangeEnd) == 0 {
+		if kv := txn.get(string(key), revision); kv != nil {
+			result = append(result, kv)
+		}
+		return result
+	}
+	for k := range txn.kv.history {
+		if !inRange(key, rangeEnd, []byte(k)) {
+			continue
+		}
+		if kv := txn.get(k, revision); kv != nil {
+			result = append(result, kv)
+		}
+	}
+	slices.SortFunc(result, func(a, b *mvccpb.KeyValue) int { return bytes.Compare(a.Key, b.Key) })
+	return result
+}
+
+func (txn *memoryTxn) write(kv *mvccpb.KeyValue) {
+	key := string(kv.Key)
+	txn.kv.history[key] = append(txn.kv.history[key], kv)
+	txn.written = append(txn.written, key)
+}
+
+func (txn *memoryTxn) rangeKeys(request *etcdserverpb.RangeRequest) (*etcdserverpb.RangeResponse, error) {
+	revision := request.Revision
+	if revision > txn.rev() {
+		return nil, rpctypes.ErrGRPCFutureRev
+	}
+	if revision <= 0 {
+		revision = txn.rev()
+	}
+	if revision < txn.kv.compacted {
+		return nil, rpctypes.ErrGRPCCompacted
+	}
+
+	kvs := txn.keys(request.Key, request.RangeEnd, revision)
+	response := &etcdserverpb.RangeResponse{
+		Header: &etcdserverpb.ResponseHeader{Revision: txn.rev()},
+		Count:  int64(len(kvs)),
+	}
+	if request.CountOnly {
+		return response, nil
+	}
+	kvs = slices.DeleteFunc(kvs, func(kv *mvccpb.KeyValue) bool {
+		return (request.MinModRevision != 0 && kv.ModRevision < request.MinModRevision) ||
+			(request.MaxModRevision != 0 && kv.ModRevision > request.MaxModRevision) ||
+			(request.MinCreateRevision != 0 && kv.CreateRevision < request.MinCreateRevision) ||
+			(request.MaxCreateRevision != 0 && kv.CreateRevision > request.MaxCreateRevision)
+	})
+
+	order := request.SortOrder
+	if request.SortTarget != etcdserverpb.RangeRequest_KEY && order == etcdserverpb.RangeRequest_NONE {
+		order = etcdserverpb.RangeRequest_ASCEND
+	}
+	if order != etcdserverpb.RangeRequest_NONE {
+		compare := sortCompare(request.SortTarget)
+		if order == etcdserverpb.RangeRequest_DESCEND {
+			ascending := compare
+			compare = func(a, b *mvccpb.KeyValue) int { return ascending(b, a) }
+		}
+		slices.SortStableFunc(kvs, compare)
+	}
+	if request.Limit > 0 && int64(len(kvs)) > request.Limit {
+		kvs = kvs[:request.Limit]
+		response.More = true
+	}
+
+	response.Kvs = make([]*mvccpb.KeyValue, 0, len(kvs))
+	for _, kv := range kvs {
+		kv := *kv
+		if request.KeysOnly {
+			kv.Value = nil
+		}
+		response.Kvs = append(response.Kvs, &kv)
+	}
+	return response, nil
+}
+
+func sortCompare(target etcdserverpb.RangeRequest_SortTarget) func(a, b *mvccpb.KeyValue) int {
+	switch target {
+	case etcdserverpb.RangeRequest_VERSION:
+		return func(a, b *mvccpb.KeyValue) int { return cmp.Compare(a.Version, b.Version) }
+	case etcdserverpb.RangeRequest_CREATE:
+		return func(a, b *mvccpb.KeyValue) int { return cmp.Compare(a.CreateRevision, b.CreateRevision) }
+	case etcdserverpb.RangeRequest_MOD:
+		return func(a, b *mvccpb.KeyValue) int { return cmp.Compare(a.ModRevision, b.ModRevision) }
+	case etcdserverpb.RangeRequest_VALUE:
+		return func(a, b *mvccpb.KeyValue) int { return bytes.Compare(a.Value, b.Value) }
+	default:
+		return func(a, b *mvccpb.KeyValue) int { return bytes.Compare(a.Key, b.Key) }
+	}
+}
+
+func (txn *memoryTxn) put(request *etcdserverpb.PutRequest) (*etcdserverpb.PutResponse, error) {
+	prev := txn.get(string(request.Key), txn.rev())
+	if (request.IgnoreValue || request.IgnoreLease) && prev == nil {
+		return nil, rpctypes.ErrGRPCKeyNotFound
+	}
+	kv := &mvccpb.KeyValue{
+		Key:            request.Key,
+		Value:          request.Value,
+		CreateRevision: txn.begin + 1,
+		ModRevision:    txn.begin + 1,
+		Version:        1,
+		Lease:          request.Lease,
+	}
+	if prev != nil {
+		kv.CreateRevision = prev.CreateRevision
+		kv.Version = prev.Version + 1
+	}
+	if request.IgnoreValue {
+		kv.Value = prev.Value
+	}
+	if request.IgnoreLease {
+		kv.Lease = prev.Lease
+	}
+	txn.write(kv)
+
+	response := &etcdserverpb.PutResponse{Header: &etcdserverpb.ResponseHeader{Revision: txn.rev()}}
+	if request.PrevKv && prev != nil {
+		prev := *prev
+		response.PrevKv = &prev
+	}
+	return response, nil
+}
+
+func (txn *memoryTxn) deleteRange(request *etcdserverpb.DeleteRangeRequest) *etcdserverpb.DeleteRangeResponse {
+	kvs := txn.keys(request.Key, request.RangeEnd, txn.rev())
+	response := &etcdserverpb.DeleteRangeResponse{
+		Header:  &etcdserverpb.ResponseHeader{},
+		Deleted: int64(len(kvs)),
+	}
+	if request.PrevKv {
+		response.PrevKvs = make([]*mvccpb.KeyValue, 0, len(kvs))
+	}
+	for _, kv := range kvs {
+		if request.PrevKv {
+			prev := *kv
+			response.PrevKvs = append(response.PrevKvs, &prev)
+		}
+		txn.write(&mvccpb.KeyValue{Key: kv.Key, ModRevision: txn.begin + 1})
+	}
+	response.Header.Revision = txn.rev()
+	return response
+}
+
+func (txn *memoryTxn) txn(request *etcdserverpb.TxnRequest) (*etcdserverpb.TxnResponse, error) {
+	succeeded := true
+	for _, compare := range request.Compare {
+		if !txn.compare(compare) {
+			succeeded = false
+			break
+		}
+	}
+	ops := request.Success
+	if !succeeded {
+		ops = request.Failure
+	}
+
+	response := &etcdserverpb.TxnResponse{
+		Header:    &etcdserverpb.ResponseHeader{},
+		Succeeded: succeeded,
+		Responses: make([]*etcdserverpb.ResponseOp, 0, len(ops)),
+	}
+	for _, op := range ops {
+		switch r := op.Request.(type) {
+		case *etcdserverpb.RequestOp_RequestRange:
+			result, err := txn.rangeKeys(r.RequestRange)
+			if err != nil {
+				return nil, err
+			}
+			response.Responses = append(response.Responses, &etcdserverpb.ResponseOp{Response: &etcdserverpb.ResponseOp_ResponseRange{ResponseRange: result}})
+		case *etcdserverpb.RequestOp_RequestPut:
+			result, err := txn.put(r.RequestPut)
+			if err != nil {
+				return nil, err
+			}
+			response.Responses = append(response.Responses, &etcdserverpb.ResponseOp{Response: &etcdserverpb.ResponseOp_ResponsePut{ResponsePut: result}})
+		case *etcdserverpb.RequestOp_RequestDeleteRange:
+			result := txn.deleteRange(r.RequestDeleteRange)
+			response.Responses = append(response.Responses, &etcdserverpb.ResponseOp{Response: &etcdserverpb.ResponseOp_ResponseDeleteRange{ResponseDeleteRange: result}})
+		case *etcdserverpb.RequestOp_RequestTxn:
+			result, err := txn.txn(r.RequestTxn)
+			if err != nil {
+				return nil, err
+			}
+			response.Responses = append(response.Responses, &etcdserverpb.ResponseOp{Response: &etcdserverpb.ResponseOp_ResponseTxn{ResponseTxn: result}})
+		}
+	}
+	return response, nil
+}
+
+func (txn *memoryTxn) compare(compare *etcdserverpb.Compare) bool {
+	kvs := txn.keys(compare.Key, compare.RangeEnd, txn.rev())
+	if len(kvs) == 0 {
+		if compare.Target == etcdserverpb.Compare_VALUE {
+			return false
+		}
+		return compareKeyValue(compare, &mvccpb.KeyValue{})
+	}
+	for _, kv := range kvs {
+		if !compareKeyValue(compare, kv) {
+			return false
+		}
+	}
+	return true
+}
+
+func compareKeyValue(compare *etcdserverpb.Compare, kv *mvccpb.KeyValue) bool {
+	var result int
+	switch compare.Target {
+	case etcdserverpb.Compare_VALUE:
+		result = bytes.Compare(kv.Value, compare.GetValue())
+	case etcdserverpb.Compare_CREATE:
+		result = cmp.Compare(kv.CreateRevision, compare.GetCreateRevision())
+	case etcdserverpb.Compare_MOD:
+		result = cmp.Compare(kv.ModRevision, compare.GetModRevision())
+	case etcdserverpb.Compare_VERSION:
+		result = cmp.Compare(kv.Version, compare.GetVersion())
+	case etcdserverpb.Compare_LEASE:
+		result = cmp.Compare(kv.Lease, compare.GetLease())
+	}
+	switch compare.Result {
+	case etcdserverpb.Compare_EQUAL:
+		return result == 0
+	case etcdserverpb.Compare_NOT_EQUAL:
+		return result != 0
+	case etcdserverpb.Compare_GREATER:
+		return result > 0
+	case etcdserverpb.Compare_LESS:
+		return result < 0
+	default:
+		return false
+	}
+}
+
+func checkPutRequest(request *etcdserverpb.PutRequest) error {
+	if len(request.Key) == 0 {
+		return rpctypes.ErrGRPCEmptyKey
+	}
+	if request.IgnoreValue && len(request.Value) != 0 {
+		return rpctypes.ErrGRPCValueProvided
+	}
+	if request.IgnoreLease && request.Lease != 0 {
+		return rpctypes.ErrGRPCLeaseProvided
+	}
+	return nil
+}
+
+func checkTxnRequest(request *etcdserverpb.TxnRequest) error {
+	if len(request.Compare) > MaxTxnOps || len(request.Success) > MaxTxnOps || len(request.Failure) > MaxTxnOps {
+		return rpctypes.ErrGRPCTooManyOps
+	}
+	for _, ops := range [][]*etcdserverpb.RequestOp{request.Success, request.Failure} {
+		for _, op := range ops {
+			if err := checkRequestOp(op); err != nil {
+				return err
+			}
+		}
+		if _, _, err := checkIntervals(ops); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkRequestOp(op *etcdserverpb.RequestOp) error {
+	switch r := op.Request.(type) {
+	case *etcdserverpb.RequestOp_RequestRange:
+		if len(r.RequestRange.Key) == 0 {
+			return rpctypes.ErrGRPCEmptyKey
+		}
+	case *etcdserverpb.RequestOp_RequestPut:
+		return checkPutRequest(r.RequestPut)
+	case *etcdserverpb.RequestOp_RequestDeleteRange:
+		if len(r.RequestDeleteRange.Key) == 0 {
+			return rpctypes.ErrGRPCEmptyKey
+		}
+	case *etcdserverpb.RequestOp_RequestTxn:
+		return checkTxnRequest(r.RequestTxn)
+	}
+	return nil
+}
+
+type interval struct {
+	key      []byte
+	rangeEnd []byte
+}
+
+func (i interval) contains(key []byte) bool {
+	return inRange(i.key, i.rangeEnd, key)
+}
+
+// checkIntervals rejects txn branches that write the same key twice, the
+// same way etcd does: puts must not repeat and must not fall into deleted
+// ranges, except for puts in the mutually exclusive branches of a nested txn.
+func checkIntervals(ops []*etcdserverpb.RequestOp) (map[string]struct{}, []interval, error) {
+	var dels []interval
+	for _, op := range ops {
+		if r, ok := op.Request.(*etcdserverpb.RequestOp_RequestDeleteRange); ok {
+			dels = append(dels, interval{key: r.RequestDeleteRange.Key, rangeEnd: r.RequestDeleteRange.RangeEnd})
+		}
+	}
+	deleted := func(key string) bool {
+		return slices.ContainsFunc(dels, func(i interval) bool { return i.contains([]byte(key)) })
+	}
+
+	puts := make(map[string]struct{})
+	for _, op := range ops {
+		r, ok := op.Request.(*etcdserverpb.RequestOp_RequestTxn)
+		if !ok {
+			continue
+		}
+		putsThen, delsThen, err := checkIntervals(r.RequestTxn.Success)
+		if err != nil {
+			return nil, nil, err
+		}
+		putsElse, delsElse, err := checkIntervals(r.RequestTxn.Failure)
+		if err != nil {
+			return nil, nil, err
+		}
+		for key := range putsThen {
+			if _, ok := puts[key]; ok || deleted(key) {
+				return nil, nil, rpctypes.ErrGRPCDuplicateKey
+			}
+			puts[key] = struct{}{}
+		}
+		for key := range putsElse {
+			if _, ok := puts[key]; ok {
+				if _, ok := putsThen[key]; !ok {
+					return nil, nil, rpctypes.ErrGRPCDuplicateKey
+				}
+			}
+			if deleted(key) {
+				return nil, nil, rpctypes.ErrGRPCDuplicateKey
+			}
+			puts[key] = struct{}{}
+		}
+		dels = append(dels, delsThen...)
+		dels = append(dels, delsElse...)
+	}
+
+	for _, op := range ops {
+		r, ok := op.Request.(*etcdserverpb.RequestOp_RequestPut)
+		if !ok {
+			continue
+		}
+		key := string(r.RequestPut.Key)
+		if _, ok := puts[key]; ok || deleted(key) {
+			return nil, nil, rpctypes.ErrGRPCDuplicateKey
+		}
+		puts[key] = struct{}{}
+	}
+	return puts, dels, nil
+}
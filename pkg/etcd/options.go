@@ -0,0 +1,192 @@
+package etcd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	_ "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/keepalive"
+)
+
+const (
+	RoundRobin = "round_robin"
+	PickFirst  = "pick_first"
+)
+
+type Option func(*options)
+
+type options struct {
+	balancer    string
+	healthCheck bool
+	retryPolicy RetryPolicy
+
+	maxCallSendMsgSize int
+	maxCallRecvMsgSize int
+	keepalive          *keepalive.ClientParameters
+	compressor         string
+	callTimeout        time.Duration
+	userAgent          string
+	logger             *slog.Logger
+	dialOpts           []grpc.DialOption
+	callOpts           []grpc.CallOption
+
+	unaryInterceptors  []grpc.UnaryClientInterceptor
+	streamInterceptors []grpc.StreamClientInterceptor
+}
+
+func defaultOptions() options {
+	return options{
+		balancer:           RoundRobin,
+		healthCheck:        true,
+		maxCallSendMsgSize: 2 * 1024 * 1024,
+		maxCallRecvMsgSize: 4 * 1024 * 1024,
+		logger:             slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+}
+
+// WithBalancer selects the load balancing policy used across endpoints:
+// RoundRobin spreads requests over all healthy members, PickFirst sticks to
+// the first reachable one and moves on only when it fails.
+func WithBalancer(balancer string) Option {
+	return func(o *options) {
+		o.balancer = balancer
+	}
+}
+
+// WithHealthCheck toggles gRPC client-side health checking of members.
+// Members that do not implement grpc.health.v1 are treated as healthy.
+func WithHealthCheck(enabled bool) Option {
+	return func(o *options) {
+		o.healthCheck = enabled
+	}
+}
+
+func WithMaxCallSendMsgSize(bytes int) Option {
+	return func(o *options) {
+		o.maxCallSendMsgSize = bytes
+	}
+}
+
+func WithMaxCallRecvMsgSize(bytes int) Option {
+	return func(o *options) {
+		o.maxCallRecvMsgSize = bytes
+	}
+}
+
+func WithKeepalive(params keepalive.ClientParameters) Option {
+	return func(o *options) {
+		o.keepalive = &params
+	}
+}
+
+// WithCompression compresses requests with the named gRPC compressor, e.g.
+// "gzip". Responses are compressed at the discretion of the server.
+func WithCompression(compressor string) Option {
+	return func(o *options) {
+		o.compressor = compressor
+	}
+}
+
+// WithCallTimeout bounds every unary call, including its retries, unless the
+// caller's context already carries an earlier deadline.
+func WithCallTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.callTimeout = timeout
+	}
+}
+
+func WithUserAgent(userAgent string) Option {
+	return func(o *options) {
+		o.userAgent = userAgent
+	}
+}
+
+// WithLogger sets the logger for the client's own diagnostics. The global
+// grpclog logger is left untouched.
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(o *options) {
+		o.dialOpts = append(o.dialOpts, opts...)
+	}
+}
+
+func WithCallOptions(opts ...grpc.CallOption) Option {
+	return func(o *options) {
+		o.callOpts = append(o.callOpts, opts...)
+	}
+}
+
+func (o options) validate() error {
+	switch o.balancer {
+	case RoundRobin, PickFirst:
+	default:
+		return fmt.Errorf("unknown balancer %q", o.balancer)
+	}
+	return nil
+}
+
+func (o options) serviceConfig() string {
+	if o.healthCheck {
+		return fmt.Sprintf(`{"loadBalancingConfig":[{%q:{}}],"healthCheckConfig":{"serviceName":""}}`, o.balancer)
+	}
+	return fmt.Sprintf(`{"loadBalancingConfig":[{%q:{}}]}`, o.balancer)
+}
+
+func (o options) dialOptions() []grpc.DialOption {
+	result := []grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithDefaultServiceConfig(o.serviceConfig()),
+	}
+	if o.keepalive != nil {
+		result = append(result, grpc.WithKeepaliveParams(*o.keepalive))
+	}
+	if o.userAgent != "" {
+		result = append(result, grpc.WithUserAgent(o.userAgent))
+	}
+
+	var unaryInterceptors []grpc.UnaryClientInterceptor
+	if o.callTimeout > 0 {
+		unaryInterceptors = append(unaryInterceptors, timeoutInterceptor(o.callTimeout))
+	}
+	unaryInterceptors = append(unaryInterceptors, o.unaryInterceptors...)
+	if o.retryPolicy.MaxRetries > 0 {
+		unaryInterceptors = append(unaryInterceptors, o.retryPolicy.unaryInterceptor(o.logger))
+	}
+	if len(unaryInterceptors) != 0 {
+		result = append(result, grpc.WithChainUnaryInterceptor(unaryInterceptors...))
+	}
+	if len(o.streamInterceptors) != 0 {
+		result = append(result, grpc.WithChainStreamInterceptor(o.streamInterceptors...))
+	}
+	return append(result, o.dialOpts...)
+}
+
+func (o options) callOptions() []grpc.CallOption {
+	result := []grpc.CallOption{
+		grpc.WaitForReady(true),
+		grpc.MaxCallSendMsgSize(o.maxCallSendMsgSize),
+		grpc.MaxCallRecvMsgSize(o.maxCallRecvMsgSize),
+	}
+	if o.compressor != "" {
+		result = append(result, grpc.UseCompressor(o.compressor))
+	}
+	return append(result, o.callOpts...)
+}
+
+func timeoutInterceptor(timeout time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
@@ -7,10 +7,12 @@ import (
 )
 
 type PutRequest struct {
-	Key         string
-	Value       string
-	PrevKv      bool
-	IgnoreValue bool
+	Key         string `json:"key,omitempty"`
+	Value       string `json:"value,omitempty"`
+	Lease       int64  `json:"lease,omitempty"`
+	PrevKv      bool   `json:"prev_kv,omitempty"`
+	IgnoreValue bool   `json:"ignore_value,omitempty"`
+	IgnoreLease bool   `json:"ignore_lease,omitempty"`
 }
 
 func (PutRequest) Request() {}
@@ -22,14 +24,30 @@ func serializePutRequest(request *PutRequest) *etcdserverpb.PutRequest {
 	return &etcdserverpb.PutRequest{
 		Key:         []byte(request.Key),
 		Value:       []byte(request.Value),
+		Lease:       request.Lease,
 		PrevKv:      request.PrevKv,
 		IgnoreValue: request.IgnoreValue,
+		IgnoreLease: request.IgnoreLease,
+	}
+}
+
+func deserializePutRequest(request *etcdserverpb.PutRequest) *PutRequest {
+	if request == nil {
+		return nil
+	}
+	return &PutRequest{
+		Key:         string(request.Key),
+		Value:       string(request.Value),
+		Lease:       request.Lease,
+		PrevKv:      request.PrevKv,
+		IgnoreValue: request.IgnoreValue,
+		IgnoreLease: request.IgnoreLease,
 	}
 }
 
 type PutResponse struct {
-	Revision int64
-	PrevKv   *KeyValue
+	Revision int64     `json:"revision,omitempty"`
+	PrevKv   *KeyValue `json:"prev_kv,omitempty"`
 }
 
 func (PutResponse) Response() {}
@@ -47,13 +65,26 @@ func deserializePutResponse(response *etcdserverpb.PutResponse) *PutResponse {
 		return nil
 	}
 	return &PutResponse{
-		Revision: response.Header.Revision,
+		Revision: response.GetHeader().GetRevision(),
 		PrevKv:   deserializeKeyValue(response.PrevKv),
 	}
 }
 
-func Put(ctx context.Context, client *Client, request *PutRequest) (*PutResponse, error) {
-	response, err := client.Put(ctx, serializePutRequest(request))
+func serializePutResponse(response *PutResponse) *etcdserverpb.PutResponse {
+	if response == nil {
+		return nil
+	}
+	return &etcdserverpb.PutResponse{
+		Header: &etcdserverpb.ResponseHeader{Revision: response.Revision},
+		PrevKv: serializeKeyValue(response.PrevKv),
+	}
+}
+
+func Put(ctx context.Context, kv KV, request *PutRequest) (*PutResponse, error) {
+	if err := Validate(request); err != nil {
+		return nil, err
+	}
+	response, err := kv.Put(ctx, serializePutRequest(request))
 	if err != nil {
 		return nil, err
 	}
@@ -4,6 +4,7 @@ import (
 	"context"
 
 	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
 )
 
 var EmptyKey = string([]byte{0})
@@ -18,18 +19,18 @@ func GetPrefix(range_end string) string {
 }
 
 type RangeRequest struct {
-	Key               string
-	RangeEnd          string
-	Limit             int64
-	Revision          int64
-	SortTarget        etcdserverpb.RangeRequest_SortTarget
-	SortOrder         etcdserverpb.RangeRequest_SortOrder
-	KeysOnly          bool
-	CountOnly         bool
-	MinModRevision    int64
-	MaxModRevision    int64
-	MinCreateRevision int64
-	MaxCreateRevision int64
+	Key               string                               `json:"key,omitempty"`
+	RangeEnd          string                               `json:"range_end,omitempty"`
+	Limit             int64                                `json:"limit,omitempty"`
+	Revision          int64                                `json:"revision,omitempty"`
+	SortTarget        etcdserverpb.RangeRequest_SortTarget `json:"sort_target,omitempty"`
+	SortOrder         etcdserverpb.RangeRequest_SortOrder  `json:"sort_order,omitempty"`
+	KeysOnly          bool                                 `json:"keys_only,omitempty"`
+	CountOnly         bool                                 `json:"count_only,omitempty"`
+	MinModRevision    int64                                `json:"min_mod_revision,omitempty"`
+	MaxModRevision    int64                                `json:"max_mod_revision,omitempty"`
+	MinCreateRevision int64                                `json:"min_create_revision,omitempty"`
+	MaxCreateRevision int64                                `json:"max_create_revision,omitempty"`
 }
 
 func (request RangeRequest) OrderByKey() *RangeRequest {
@@ -89,11 +90,31 @@ func serializeRangeRequest(request *RangeRequest) *etcdserverpb.RangeRequest {
 	}
 }
 
+func deserializeRangeRequest(request *etcdserverpb.RangeRequest) *RangeRequest {
+	if request == nil {
+		return nil
+	}
+	return &RangeRequest{
+		Key:               string(request.Key),
+		RangeEnd:          string(request.RangeEnd),
+		Limit:             request.Limit,
+		Revision:          request.Revision,
+		SortOrder:         request.SortOrder,
+		SortTarget:        request.SortTarget,
+		KeysOnly:          request.KeysOnly,
+		CountOnly:         request.CountOnly,
+		MinModRevision:    request.MinModRevision,
+		MaxModRevision:    request.MaxModRevision,
+		MinCreateRevision: request.MinCreateRevision,
+		MaxCreateRevision: request.MaxCreateRevision,
+	}
+}
+
 type RangeResponse struct {
-	Revision int64
-	Count    int64
-	More     bool
-	Kvs      []*KeyValue
+	Revision int64       `json:"revision,omitempty"`
+	Count    int64       `json:"count,omitempty"`
+	More     bool        `json:"more,omitempty"`
+	Kvs      []*KeyValue `json:"kvs"`
 }
 
 func (RangeResponse) Response() {}
@@ -111,7 +132,7 @@ func deserializeRangeResponse(response *etcdserverpb.RangeResponse) *RangeRespon
 		return nil
 	}
 	result := &RangeResponse{
-		Revision: response.Header.Revision,
+		Revision: response.GetHeader().GetRevision(),
 		More:     response.More,
 		Count:    response.Count,
 		Kvs:      make([]*KeyValue, 0, len(response.Kvs)),
@@ -122,8 +143,27 @@ func deserializeRangeResponse(response *etcdserverpb.RangeResponse) *RangeRespon
 	return result
 }
 
-func Range(ctx context.Context, client *Client, request *RangeRequest) (*RangeResponse, error) {
-	response, err := client.Range(ctx, serializeRangeRequest(request))
+func serializeRangeResponse(response *RangeResponse) *etcdserverpb.RangeResponse {
+	if response == nil {
+		return nil
+	}
+	result := &etcdserverpb.RangeResponse{
+		Header: &etcdserverpb.ResponseHeader{Revision: response.Revision},
+		More:   response.More,
+		Count:  response.Count,
+		Kvs:    make([]*mvccpb.KeyValue, 0, len(response.Kvs)),
+	}
+	for _, kv := range response.Kvs {
+		result.Kvs = append(result.Kvs, serializeKeyValue(kv))
+	}
+	return result
+}
+
+func Range(ctx context.Context, kv KV, request *RangeRequest) (*RangeResponse, error) {
+	if err := Validate(request); err != nil {
+		return nil, err
+	}
+	response, err := kv.Range(ctx, serializeRangeRequest(request))
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,319 @@
+package etcd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	MethodRange   = "Range"
+	MethodPut     = "Put"
+	MethodDelete  = "Delete"
+	MethodTxn     = "Txn"
+	MethodCompact = "Compact"
+)
+
+// KVMethod maps the full gRPC method name of a KV call to its method.
+func KVMethod(fullMethod string) (string, bool) {
+	switch fullMethod {
+	case "/etcdserverpb.KV/Range":
+		return MethodRange, true
+	case "/etcdserverpb.KV/Put":
+		return MethodPut, true
+	case "/etcdserverpb.KV/DeleteRange":
+		return MethodDelete, true
+	case "/etcdserverpb.KV/Txn":
+		return MethodTxn, true
+	case "/etcdserverpb.KV/Compact":
+		return MethodCompact, true
+	default:
+		return "", false
+	}
+}
+
+// Record is a single call observed by a Recorder. Request and Response hold
+// the etcdserverpb messages of the call.
+type Record struct {
+	Method   string
+	Request  any
+	Response any
+	Err      error
+	Start    time.Time
+	Duration time.Duration
+}
+
+// Recorder is a KV that passes calls through to another KV and keeps every
+// call together with its outcome and timing.
+type Recorder struct {
+	kv      KV
+	mu      sync.Mutex
+	records []Record
+}
+
+func NewRecorder(kv KV) *Recorder {
+	return &Recorder{kv: kv}
+}
+
+func (r *Recorder) Records() []Record {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.records)
+}
+
+func (r *Recorder) Range(ctx context.Context, request *etcdserverpb.RangeRequest) (*etcdserverpb.RangeResponse, error) {
+	return record(ctx, r, MethodRange, request, r.kv.Range)
+}
+
+func (r *Recorder) Put(ctx context.Context, request *etcdserverpb.PutRequest) (*etcdserverpb.PutResponse, error) {
+	return record(ctx, r, MethodPut, request, r.kv.Put)
+}
+
+func (r *Recorder) Delete(ctx context.Context, request *etcdserverpb.DeleteRangeRequest) (*etcdserverpb.DeleteRangeResponse, error) {
+	return record(ctx, r, MethodDelete, request, r.kv.Delete)
+}
+
+func (r *Recorder) Txn(ctx context.Context, request *etcdserverpb.TxnRequest) (*etcdserverpb.TxnResponse, error) {
+	return record(ctx, r, MethodTxn, request, r.kv.Txn)
+}
+
+func (r *Recorder) Compact(ctx context.Context, request *etcdserverpb.CompactionRequest) (*etcdserverpb.CompactionResponse, error) {
+	return record(ctx, r, MethodCompact, request, r.kv.Compact)
+}
+
+func record[Req, Resp any](ctx context.Context, r *Recorder, method string, request Req, call func(context.Context, Req) (Resp, error)) (Resp, error) {
+	start := time.Now()
+	response, err := call(ctx, request)
+	rec := Record{
+		Method:   method,
+		Request:  request,
+		Err:      err,
+		Start:    start,
+		Duration: time.Since(start),
+	}
+	if err == nil {
+		rec.Response = response
+	}
+	r.mu.Lock()
+	r.records = append(r.records, rec)
+	r.mu.Unlock()
+	return response, err
+}
+
+// Replayer is a KV that answers calls with previously recorded outcomes. A
+// call is matched to the first unused record of the same method with an equal
+// request, so concurrent callers may replay in any order.
+type Replayer struct {
+	mu      sync.Mutex
+	records []Record
+	used    []bool
+}
+
+func NewReplayer(records []Record) *Replayer {
+	return &Replayer{
+		records: records,
+		used:    make([]bool, len(records)),
+	}
+}
+
+// Remaining returns the number of records that were not replayed yet.
+func (r *Replayer) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	remaining := 0
+	for _, used := range r.used {
+		if !used {
+			remaining++
+		}
+	}
+	return remaining
+}
+
+func (r *Replayer) Range(_ context.Context, request *etcdserverpb.RangeRequest) (*etcdserverpb.RangeResponse, error) {
+	return replay[*etcdserverpb.RangeResponse](r, MethodRange, request)
+}
+
+func (r *Replayer) Put(_ context.Context, request *etcdserverpb.PutRequest) (*etcdserverpb.PutResponse, error) {
+	return replay[*etcdserverpb.PutResponse](r, MethodPut, request)
+}
+
+func (r *Replayer) Delete(_ context.Context, request *etcdserverpb.DeleteRangeRequest) (*etcdserverpb.DeleteRangeResponse, error) {
+	return replay[*etcdserverpb.DeleteRangeResponse](r, MethodDelete, request)
+}
+
+func (r *Replayer) Txn(_ context.Context, request *etcdserverpb.TxnRequest) (*etcdserverpb.TxnResponse, error) {
+	return replay[*etcdserverpb.TxnResponse](r, MethodTxn, request)
+}
+
+func (r *Replayer) Compact(_ context.Context, request *etcdserverpb.CompactionRequest) (*etcdserverpb.CompactionResponse, error) {
+	return replay[*etcdserverpb.CompactionResponse](r, MethodCompact, request)
+}
+
+type marshaler interface {
+	Marshal() ([]byte, error)
+}
+
+func replay[Resp any](r *Replayer, method string, request marshaler) (Resp, error) {
+	var empty Resp
+	data, err := request.Marshal()
+	if err != nil {
+		return empty, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, rec := range r.records {
+		if r.used[i] || rec.Method != method {
+			continue
+		}
+		recorded, ok := rec.Request.(marshaler)
+		if !ok {
+			continue
+		}
+		if recordedData, err := recorded.Marshal(); err != nil || !bytes.Equal(data, recordedData) {
+			continue
+		}
+		r.used[i] = true
+		if rec.Err != nil {
+			return empty, rec.Err
+		}
+		response, ok := rec.Response.(Resp)
+		if !ok {
+			return empty, fmt.Errorf("etcd: recorded %s response has type %T", method, rec.Response)
+		}
+		return response, nil
+	}
+	return empty, fmt.Errorf("etcd: %s request was not recorded", method)
+}
+
+type protoMessage interface {
+	Marshal() ([]byte, error)
+	Unmarshal(data []byte) error
+}
+
+func newProtoMessages(method string) (protoMessage, protoMessage, error) {
+	switch method {
+	case MethodRange:
+		return &etcdserverpb.RangeRequest{}, &etcdserverpb.RangeResponse{}, nil
+	case MethodPut:
+		return &etcdserverpb.PutRequest{}, &etcdserverpb.PutResponse{}, nil
+	case MethodDelete:
+		return &etcdserverpb.DeleteRangeRequest{}, &etcdserverpb.DeleteRangeResponse{}, nil
+	case MethodTxn:
+		return &etcdserverpb.TxnRequest{}, &etcdserverpb.TxnResponse{}, nil
+	case MethodCompact:
+		return &etcdserverpb.CompactionRequest{}, &etcdserverpb.CompactionResponse{}, nil
+	default:
+		return nil, nil, fmt.Errorf("%w: method %q", ErrUnknownRequest, method)
+	}
+}
+
+// UnmarshalRecord decodes the wire messages of a call into a Record. The
+// response is left nil when it is empty, as for a failed call.
+func UnmarshalRecord(method string, request, response []byte) (Record, error) {
+	requestMessage, responseMessage, err := newProtoMessages(method)
+	if err != nil {
+		return Record{}, err
+	}
+	rec := Record{Method: method, Request: requestMessage}
+	if err := requestMessage.Unmarshal(request); err != nil {
+		return Record{}, err
+	}
+	if response != nil {
+		if err := responseMessage.Unmarshal(response); err != nil {
+			return Record{}, err
+		}
+		rec.Response = responseMessage
+	}
+	return rec, nil
+}
+
+// recordJSON keeps the messages of a Record protobuf encoded so that keys and
+// values of any bytes survive, and its error as a gRPC status.
+type recordJSON struct {
+	Method   string        `json:"method"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Request  []byte        `json:"request"`
+	Response []byte        `json:"response,omitempty"`
+	Code     codes.Code    `json:"code,omitempty"`
+	Error    string        `json:"error,omitempty"`
+}
+
+func (rec Record) MarshalJSON() ([]byte, error) {
+	result := recordJSON{Method: rec.Method, Start: rec.Start, Duration: rec.Duration}
+	var err error
+	if result.Request, err = marshalMessage(rec.Request); err != nil {
+		return nil, err
+	}
+	if rec.Response != nil {
+		if result.Response, err = marshalMessage(rec.Response); err != nil {
+			return nil, err
+		}
+	}
+	if rec.Err != nil {
+		s := status.Convert(rec.Err)
+		result.Code, result.Error = s.Code(), s.Message()
+	}
+	return json.Marshal(result)
+}
+
+func (rec *Record) UnmarshalJSON(data []byte) error {
+	var decoded recordJSON
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	result, err := UnmarshalRecord(decoded.Method, decoded.Request, decoded.Response)
+	if err != nil {
+		return err
+	}
+	result.Start, result.Duration = decoded.Start, decoded.Duration
+	if decoded.Error != "" || decoded.Code != codes.OK {
+		result.Err = status.Error(decoded.Code, decoded.Error)
+	}
+	*rec = result
+	return nil
+}
+
+func marshalMessage(message any) ([]byte, error) {
+	m, ok := message.(marshaler)
+	if !ok {
+		return nil, fmt.Errorf("etcd: cannot encode %T", message)
+	}
+	return m.Marshal()
+}
+
+// Invoke calls the method of kv with a recorded etcdserverpb request.
+func Invoke(ctx context.Context, kv KV, method string, request any) (any, error) {
+	switch r := request.(type) {
+	case *etcdserverpb.RangeRequest:
+		if method == MethodRange {
+			return kv.Range(ctx, r)
+		}
+	case *etcdserverpb.PutRequest:
+		if method == MethodPut {
+			return kv.Put(ctx, r)
+		}
+	case *etcdserverpb.DeleteRangeRequest:
+		if method == MethodDelete {
+			return kv.Delete(ctx, r)
+		}
+	case *etcdserverpb.TxnRequest:
+		if method == MethodTxn {
+			return kv.Txn(ctx, r)
+		}
+	case *etcdserverpb.CompactionRequest:
+		if method == MethodCompact {
+			return kv.Compact(ctx, r)
+		}
+	}
+	return nil, fmt.Errorf("%w: %T for method %q", ErrUnknownRequest, request, method)
+}
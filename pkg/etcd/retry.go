@@ -0,0 +1,202 @@
+package etcd
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"math/rand"
+	"slices"
+	"time"
+
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type RetryPolicy struct {
+	MaxRetries  uint
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// Jitter is the fraction of each backoff that is randomized, e.g. 0.2
+	// waits anywhere between 80% and 120% of the nominal backoff.
+	Jitter float64
+	Codes  []codes.Code
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries:  3,
+	BaseBackoff: 25 * time.Millisecond,
+	MaxBackoff:  time.Second,
+	Jitter:      0.2,
+	Codes:       []codes.Code{codes.Unavailable, codes.ResourceExhausted, codes.Aborted},
+}
+
+// WithRetryPolicy enables retries of idempotent requests: ranges, read-only
+// txns, txns guarded by a compare that the txn itself invalidates, and
+// requests whose context is marked with WithIdempotent. Other requests are
+// never retried, since repeating them could apply a write twice.
+//
+// A guarded txn whose ack was lost is retried against its own write, so it
+// returns Succeeded false although it committed. Callers that retry a compare
+// and swap on failure must check the values read by the failure branch
+// before applying their update again.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(o *options) {
+		o.retryPolicy = policy
+	}
+}
+
+type idempotentKey struct{}
+
+// WithIdempotent marks requests issued with the returned context as safe to
+// retry regardless of their type.
+func WithIdempotent(ctx context.Context) context.Context {
+	return context.WithValue(ctx, idempotentKey{}, true)
+}
+
+type retriesKey struct{}
+
+// WithRetries makes the client store into retries the number of retries
+// performed by the last request issued with the returned context. A guarded
+// txn that failed its compare after retries may have committed on an earlier
+// attempt, see WithRetryPolicy.
+func WithRetries(ctx context.Context, retries *int) context.Context {
+	return context.WithValue(ctx, retriesKey{}, retries)
+}
+
+func (policy RetryPolicy) isRetryable(err error) bool {
+	return slices.Contains(policy.Codes, status.Code(err))
+}
+
+func (policy RetryPolicy) backoff(attempt uint) time.Duration {
+	backoff := policy.BaseBackoff << attempt
+	if backoff > policy.MaxBackoff || backoff <= 0 {
+		backoff = policy.MaxBackoff
+	}
+	return time.Duration(float64(backoff) * (1 + policy.Jitter*(2*rand.Float64()-1)))
+}
+
+func (policy RetryPolicy) unaryInterceptor(logger *slog.Logger) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		idempotent, _ := ctx.Value(idempotentKey{}).(bool)
+		idempotent = idempotent || isIdempotent(req)
+		retries, _ := ctx.Value(retriesKey{}).(*int)
+
+		for attempt := uint(0); ; attempt++ {
+			if retries != nil {
+				*retries = int(attempt)
+			}
+			err := invoker(ctx, method, req, reply, cc, opts...)
+			if err == nil || !idempotent || attempt >= policy.MaxRetries || !policy.isRetryable(err) {
+				return err
+			}
+			backoff := policy.backoff(attempt)
+			logger.LogAttrs(ctx, slog.LevelDebug, "retrying etcd request",
+				slog.String("method", method),
+				slog.Uint64("attempt", uint64(attempt+1)),
+				slog.Duration("backoff", backoff),
+				slog.String("error", err.Error()),
+			)
+			timer := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return err
+			case <-timer.C:
+			}
+		}
+	}
+}
+
+func isIdempotent(request any) bool {
+	switch r := request.(type) {
+	case *etcdserverpb.RangeRequest:
+		return true
+	case *etcdserverpb.TxnRequest:
+		return isIdempotentTxn(r)
+	default:
+		return false
+	}
+}
+
+// isIdempotentTxn reports whether applying the txn twice has the same effect
+// as applying it once. It holds for read-only txns and for txns whose
+// successful branch writes a key that one of the compares pins: once the
+// first attempt applies, the compare fails and only the read-only failure
+// branch can run. A compare with a zero value, which holds for an absent key,
+// is only pinned by a put, since deleting an absent key changes nothing.
+func isIdempotentTxn(txn *etcdserverpb.TxnRequest) bool {
+	if !isReadOnlyOps(txn.Failure) {
+		return false
+	}
+	if isReadOnlyOps(txn.Success) {
+		return true
+	}
+	for _, compare := range txn.Compare {
+		if compare.Result != etcdserverpb.Compare_EQUAL || len(compare.RangeEnd) != 0 {
+			continue
+		}
+		var zero bool
+		switch compare.Target {
+		case etcdserverpb.Compare_MOD:
+			zero = compare.GetModRevision() == 0
+		case etcdserverpb.Compare_VERSION:
+			zero = compare.GetVersion() == 0
+		case etcdserverpb.Compare_CREATE:
+			if compare.GetCreateRevision() != 0 {
+				continue
+			}
+			zero = true
+		default:
+			continue
+		}
+		if writesKey(txn.Success, compare.Key, zero) {
+			return true
+		}
+	}
+	return false
+}
+
+func isReadOnlyOps(ops []*etcdserverpb.RequestOp) bool {
+	for _, op := range ops {
+		switch r := op.Request.(type) {
+		case *etcdserverpb.RequestOp_RequestRange:
+		case *etcdserverpb.RequestOp_RequestTxn:
+			if !isReadOnlyOps(r.RequestTxn.Success) || !isReadOnlyOps(r.RequestTxn.Failure) {
+				return false
+			}
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// writesKey reports whether ops put key or, unless putOnly, delete it.
+func writesKey(ops []*etcdserverpb.RequestOp, key []byte, putOnly bool) bool {
+	for _, op := range ops {
+		switch r := op.Request.(type) {
+		case *etcdserverpb.RequestOp_RequestPut:
+			if bytes.Equal(r.RequestPut.Key, key) {
+				return true
+			}
+		case *etcdserverpb.RequestOp_RequestDeleteRange:
+			if !putOnly && inRange(r.RequestDeleteRange.Key, r.RequestDeleteRange.RangeEnd, key) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func inRange(key, rangeEnd, target []byte) bool {
+	switch {
+	case len(rangeEnd) == 0:
+		return bytes.Equal(key, target)
+	case bytes.Equal(rangeEnd, []byte(EmptyKey)):
+		return bytes.Compare(key, target) <= 0
+	default:
+		return bytes.Compare(key, target) <= 0 && bytes.Compare(target, rangeEnd) < 0
+	}
+}
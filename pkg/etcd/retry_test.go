@@ -0,0 +1,156 @@
+package etcd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+)
+
+func TestIsIdempotentTxn(t *testing.T) {
+	put := func(key string) *etcdserverpb.RequestOp {
+		return &etcdserverpb.RequestOp{Request: &etcdserverpb.RequestOp_RequestPut{
+			RequestPut: &etcdserverpb.PutRequest{Key: []byte(key), Value: []byte("value")},
+		}}
+	}
+	get := func(key string) *etcdserverpb.RequestOp {
+		return &etcdserverpb.RequestOp{Request: &etcdserverpb.RequestOp_RequestRange{
+			RequestRange: &etcdserverpb.RangeRequest{Key: []byte(key)},
+		}}
+	}
+	del := func(key, rangeEnd string) *etcdserverpb.RequestOp {
+		return &etcdserverpb.RequestOp{Request: &etcdserverpb.RequestOp_RequestDeleteRange{
+			RequestDeleteRange: &etcdserverpb.DeleteRangeRequest{Key: []byte(key), RangeEnd: []byte(rangeEnd)},
+		}}
+	}
+	absent := &etcdserverpb.Compare{
+		Result:      etcdserverpb.Compare_EQUAL,
+		Target:      etcdserverpb.Compare_VERSION,
+		Key:         []byte("key"),
+		TargetUnion: &etcdserverpb.Compare_Version{Version: 0},
+	}
+	compare := func(target etcdserverpb.Compare_CompareTarget, key, rangeEnd string) *etcdserverpb.Compare {
+		c := &etcdserverpb.Compare{Result: etcdserverpb.Compare_EQUAL, Target: target, Key: []byte(key), RangeEnd: []byte(rangeEnd)}
+		switch target {
+		case etcdserverpb.Compare_MOD:
+			c.TargetUnion = &etcdserverpb.Compare_ModRevision{ModRevision: 1}
+		case etcdserverpb.Compare_VERSION:
+			c.TargetUnion = &etcdserverpb.Compare_Version{Version: 1}
+		case etcdserverpb.Compare_CREATE:
+			c.TargetUnion = &etcdserverpb.Compare_CreateRevision{CreateRevision: 0}
+		case etcdserverpb.Compare_VALUE:
+			c.TargetUnion = &etcdserverpb.Compare_Value{Value: []byte("value")}
+		}
+		return c
+	}
+
+	for _, tc := range []struct {
+		name       string
+		txn        *etcdserverpb.TxnRequest
+		idempotent bool
+	}{
+		{
+			name: "ReadOnly",
+			txn: &etcdserverpb.TxnRequest{
+				Compare: []*etcdserverpb.Compare{compare(etcdserverpb.Compare_VALUE, "key", "")},
+				Success: []*etcdserverpb.RequestOp{get("key")},
+				Failure: []*etcdserverpb.RequestOp{get("other")},
+			},
+			idempotent: true,
+		},
+		{
+			name: "Unguarded",
+			txn: &etcdserverpb.TxnRequest{
+				Success: []*etcdserverpb.RequestOp{put("key")},
+			},
+		},
+		{
+			name: "ModGuard",
+			txn: &etcdserverpb.TxnRequest{
+				Compare: []*etcdserverpb.Compare{compare(etcdserverpb.Compare_MOD, "key", "")},
+				Success: []*etcdserverpb.RequestOp{put("key")},
+				Failure: []*etcdserverpb.RequestOp{get("key")},
+			},
+			idempotent: true,
+		},
+		{
+			name: "VersionGuard",
+			txn: &etcdserverpb.TxnRequest{
+				Compare: []*etcdserverpb.Compare{compare(etcdserverpb.Compare_VERSION, "key", "")},
+				Success: []*etcdserverpb.RequestOp{put("key")},
+			},
+			idempotent: true,
+		},
+		{
+			name: "CreateGuard",
+			txn: &etcdserverpb.TxnRequest{
+				Compare: []*etcdserverpb.Compare{compare(etcdserverpb.Compare_CREATE, "key", "")},
+				Success: []*etcdserverpb.RequestOp{put("key")},
+			},
+			idempotent: true,
+		},
+		{
+			name: "DeleteUnderGuard",
+			txn: &etcdserverpb.TxnRequest{
+				Compare: []*etcdserverpb.Compare{compare(etcdserverpb.Compare_MOD, "key", "")},
+				Success: []*etcdserverpb.RequestOp{del("a", "z"), put("other")},
+			},
+			idempotent: true,
+		},
+		{
+			name: "DeleteUnderZeroGuard",
+			txn: &etcdserverpb.TxnRequest{
+				Compare: []*etcdserverpb.Compare{absent},
+				Success: []*etcdserverpb.RequestOp{del("key", ""), put("other")},
+			},
+		},
+		{
+			name: "DeleteUnderCreateGuard",
+			txn: &etcdserverpb.TxnRequest{
+				Compare: []*etcdserverpb.Compare{compare(etcdserverpb.Compare_CREATE, "key", "")},
+				Success: []*etcdserverpb.RequestOp{del("a", "z"), put("other")},
+			},
+		},
+		{
+			name: "PutUnderZeroGuard",
+			txn: &etcdserverpb.TxnRequest{
+				Compare: []*etcdserverpb.Compare{absent},
+				Success: []*etcdserverpb.RequestOp{put("key")},
+			},
+			idempotent: true,
+		},
+		{
+			name: "ValueGuard",
+			txn: &etcdserverpb.TxnRequest{
+				Compare: []*etcdserverpb.Compare{compare(etcdserverpb.Compare_VALUE, "key", "")},
+				Success: []*etcdserverpb.RequestOp{put("key")},
+			},
+		},
+		{
+			name: "GuardOnOtherKey",
+			txn: &etcdserverpb.TxnRequest{
+				Compare: []*etcdserverpb.Compare{compare(etcdserverpb.Compare_MOD, "other", "")},
+				Success: []*etcdserverpb.RequestOp{put("key")},
+			},
+		},
+		{
+			name: "RangeCompare",
+			txn: &etcdserverpb.TxnRequest{
+				Compare: []*etcdserverpb.Compare{compare(etcdserverpb.Compare_MOD, "key", "kez")},
+				Success: []*etcdserverpb.RequestOp{put("key")},
+			},
+		},
+		{
+			name: "WritingFailure",
+			txn: &etcdserverpb.TxnRequest{
+				Compare: []*etcdserverpb.Compare{compare(etcdserverpb.Compare_MOD, "key", "")},
+				Success: []*etcdserverpb.RequestOp{put("key")},
+				Failure: []*etcdserverpb.RequestOp{put("other")},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.idempotent, isIdempotentTxn(tc.txn))
+		})
+	}
+}
@@ -0,0 +1,253 @@
+package etcd
+
+import (
+	"context"
+	"errors"
+	"slices"
+)
+
+// STM is a software transactional memory over the keys of a KV. Reads are
+// recorded, writes are buffered and applied by a single txn on commit.
+type STM interface {
+	Get(key string) (string, error)
+	Rev(key string) (int64, error)
+	Put(key, value string)
+	Del(key string)
+}
+
+type Isolation int
+
+const (
+	// SerializableSnapshot reads every key at the revision of the first read
+	// and fails the commit if any read or written key was modified since.
+	SerializableSnapshot Isolation = iota
+	// Serializable reads every key at the revision of the first read and
+	// fails the commit if any read key was modified since.
+	Serializable
+	// RepeatableReads reads keys at the latest revision, caches them for
+	// repeated reads and fails the commit if any read key was modified since.
+	RepeatableReads
+)
+
+var ErrConflictLimit = errors.New("etcd: stm conflict limit exceeded")
+
+type STMOption func(*stmOptions)
+
+type stmOptions struct {
+	isolation    Isolation
+	prefetch     []string
+	maxConflicts int
+	conflicts    *int
+}
+
+func WithIsolation(isolation Isolation) STMOption {
+	return func(o *stmOptions) {
+		o.isolation = isolation
+	}
+}
+
+// WithPrefetch reads the keys in one round trip before the first attempt.
+func WithPrefetch(keys ...string) STMOption {
+	return func(o *stmOptions) {
+		o.prefetch = append(o.prefetch, keys...)
+	}
+}
+
+// WithMaxConflicts makes NewSTM give up with ErrConflictLimit after the given
+// number of failed commits. By default it retries until ctx is done.
+func WithMaxConflicts(maxConflicts int) STMOption {
+	return func(o *stmOptions) {
+		o.maxConflicts = maxConflicts
+	}
+}
+
+// WithConflicts makes NewSTM store into conflicts the number of commits that
+// failed and were retried.
+func WithConflicts(conflicts *int) STMOption {
+	return func(o *stmOptions) {
+		o.conflicts = conflicts
+	}
+}
+
+// NewSTM runs apply inside an STM and commits its writes, rerunning apply
+// until the commit succeeds. It returns the response of the committed txn.
+func NewSTM(ctx context.Context, kv KV, apply func(STM) error, opts ...STMOption) (*TxnResponse, error) {
+	var o stmOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	s := &stm{ctx: ctx, kv: kv, isolation: o.isolation}
+	s.reset()
+	if len(o.prefetch) != 0 {
+		if err := s.prefetch(o.prefetch); err != nil {
+			return nil, err
+		}
+	}
+
+	for conflicts := 0; ; conflicts++ {
+		if o.conflicts != nil {
+			*o.conflicts = conflicts
+		}
+		if o.maxConflicts > 0 && conflicts > o.maxConflicts {
+			return nil, ErrConflictLimit
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := apply(s); err != nil {
+			return nil, err
+		}
+		readKeys := s.readKeys()
+		response, err := s.commit(readKeys)
+		if err != nil || response.Succeeded {
+			return response, err
+		}
+		s.refresh(readKeys, response)
+	}
+}
+
+type stm struct {
+	ctx       context.Context
+	kv        KV
+	isolation Isolation
+
+	revision int64
+	reads    map[string]*KeyValue
+	writes   map[string]*string
+}
+
+func (s *stm) reset() {
+	s.revision = 0
+	s.reads = make(map[string]*KeyValue)
+	s.writes = make(map[string]*string)
+}
+
+func (s *stm) Get(key string) (string, error) {
+	if value, ok := s.writes[key]; ok {
+		if value == nil {
+			return "", nil
+		}
+		return *value, nil
+	}
+	kv, err := s.fetch(key)
+	if err != nil || kv == nil {
+		return "", err
+	}
+	return kv.Value, nil
+}
+
+func (s *stm) Rev(key string) (int64, error) {
+	kv, err := s.fetch(key)
+	if err != nil || kv == nil {
+		return 0, err
+	}
+	return kv.ModRevision, nil
+}
+
+func (s *stm) Put(key, value string) {
+	s.writes[key] = &value
+}
+
+func (s *stm) Del(key string) {
+	s.writes[key] = nil
+}
+
+func (s *stm) fetch(key string) (*KeyValue, error) {
+	if kv, ok := s.reads[key]; ok {
+		return kv, nil
+	}
+	request := &RangeRequest{Key: key}
+	if s.isolation != RepeatableReads {
+		request.Revision = s.revision
+	}
+	response, err := Range(s.ctx, s.kv, request)
+	if err != nil {
+		return nil, err
+	}
+	if s.revision == 0 {
+		s.revision = response.Revision
+	}
+	var kv *KeyValue
+	if len(response.Kvs) != 0 {
+		kv = response.Kvs[0]
+	}
+	s.reads[key] = kv
+	return kv, nil
+}
+
+func (s *stm) prefetch(keys []string) error {
+	response, err := Txn(s.ctx, s.kv, &TxnRequest{Success: readOps(keys)})
+	if err != nil {
+		return err
+	}
+	s.refresh(keys, response)
+	return nil
+}
+
+// refresh replaces the read set with the ranges of keys returned by a txn,
+// so the next attempt starts from the state the txn observed.
+func (s *stm) refresh(keys []string, response *TxnResponse) {
+	s.reset()
+	s.revision = response.Revision
+	for i, key := range keys {
+		s.reads[key] = nil
+		if rangeResponse, ok := response.Responses[i].(*RangeResponse); ok && len(rangeResponse.Kvs) != 0 {
+			s.reads[key] = rangeResponse.Kvs[0]
+		}
+	}
+}
+
+func (s *stm) commit(readKeys []string) (*TxnResponse, error) {
+	compare := make([]Compare, 0, len(readKeys)+len(s.writes))
+	for _, key := range readKeys {
+		var modRevision int64
+		if kv := s.reads[key]; kv != nil {
+			modRevision = kv.ModRevision
+		}
+		compare = append(compare, Compare{Key: key}.Equal().SetModRevision(modRevision))
+	}
+
+	writeKeys := sortedKeys(s.writes)
+	if s.isolation == SerializableSnapshot && s.revision != 0 {
+		for _, key := range writeKeys {
+			if _, ok := s.reads[key]; !ok {
+				compare = append(compare, Compare{Key: key}.Less().SetModRevision(s.revision+1))
+			}
+		}
+	}
+
+	success := make([]Request, 0, len(writeKeys))
+	for _, key := range writeKeys {
+		if value := s.writes[key]; value != nil {
+			success = append(success, &PutRequest{Key: key, Value: *value})
+		} else {
+			success = append(success, &DeleteRequest{Key: key})
+		}
+	}
+	return Txn(s.ctx, s.kv, &TxnRequest{
+		Compare: compare,
+		Success: success,
+		Failure: readOps(readKeys),
+	})
+}
+
+func (s *stm) readKeys() []string {
+	return sortedKeys(s.reads)
+}
+
+func readOps(keys []string) []Request {
+	ops := make([]Request, 0, len(keys))
+	for _, key := range keys {
+		ops = append(ops, &RangeRequest{Key: key})
+	}
+	return ops
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
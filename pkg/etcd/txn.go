@@ -2,6 +2,8 @@ package etcd
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
 	"go.etcd.io/etcd/api/v3/etcdserverpb"
 )
@@ -14,40 +16,105 @@ type TxnRequest struct {
 
 func (TxnRequest) Request() {}
 
-func serializeRequestOp(request Request) *etcdserverpb.RequestOp {
+func serializeRequestOp(request Request) (*etcdserverpb.RequestOp, error) {
 	switch r := request.(type) {
 	case *DeleteRequest:
-		return &etcdserverpb.RequestOp{Request: &etcdserverpb.RequestOp_RequestDeleteRange{RequestDeleteRange: serializeDeleteRequest(r)}}
+		return &etcdserverpb.RequestOp{Request: &etcdserverpb.RequestOp_RequestDeleteRange{RequestDeleteRange: serializeDeleteRequest(r)}}, nil
 	case *PutRequest:
-		return &etcdserverpb.RequestOp{Request: &etcdserverpb.RequestOp_RequestPut{RequestPut: serializePutRequest(r)}}
+		return &etcdserverpb.RequestOp{Request: &etcdserverpb.RequestOp_RequestPut{RequestPut: serializePutRequest(r)}}, nil
 	case *RangeRequest:
-		return &etcdserverpb.RequestOp{Request: &etcdserverpb.RequestOp_RequestRange{RequestRange: serializeRangeRequest(r)}}
+		return &etcdserverpb.RequestOp{Request: &etcdserverpb.RequestOp_RequestRange{RequestRange: serializeRangeRequest(r)}}, nil
 	case *TxnRequest:
-		return &etcdserverpb.RequestOp{Request: &etcdserverpb.RequestOp_RequestTxn{RequestTxn: serializeTxnRequest(r)}}
+		txn, err := serializeTxnRequest(r)
+		if err != nil {
+			return nil, err
+		}
+		return &etcdserverpb.RequestOp{Request: &etcdserverpb.RequestOp_RequestTxn{RequestTxn: txn}}, nil
+	case *CompactRequest:
+		return nil, ErrCompactInTxn
 	default:
-		panic("unknown request type")
+		return nil, fmt.Errorf("%w: %T", ErrUnknownRequest, request)
 	}
 }
 
-func serializeTxnRequest(request *TxnRequest) *etcdserverpb.TxnRequest {
+func serializeRequestOps(requests []Request) ([]*etcdserverpb.RequestOp, error) {
+	result := make([]*etcdserverpb.RequestOp, 0, len(requests))
+	for _, request := range requests {
+		op, err := serializeRequestOp(request)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, op)
+	}
+	return result, nil
+}
+
+func serializeTxnRequest(request *TxnRequest) (*etcdserverpb.TxnRequest, error) {
 	if request == nil {
-		return nil
+		return nil, nil
 	}
 	result := &etcdserverpb.TxnRequest{
 		Compare: make([]*etcdserverpb.Compare, 0, len(request.Compare)),
-		Success: make([]*etcdserverpb.RequestOp, 0, len(request.Success)),
-		Failure: make([]*etcdserverpb.RequestOp, 0, len(request.Failure)),
 	}
 	for _, compare := range request.Compare {
-		result.Compare = append(result.Compare, serializeCompare(compare))
+		serialized, err := serializeCompare(compare)
+		if err != nil {
+			return nil, err
+		}
+		result.Compare = append(result.Compare, serialized)
+	}
+	var err error
+	if result.Success, err = serializeRequestOps(request.Success); err != nil {
+		return nil, err
 	}
-	for _, success := range request.Success {
-		result.Success = append(result.Success, serializeRequestOp(success))
+	if result.Failure, err = serializeRequestOps(request.Failure); err != nil {
+		return nil, err
 	}
-	for _, failure := range request.Failure {
-		result.Failure = append(result.Failure, serializeRequestOp(failure))
+	return result, nil
+}
+
+func deserializeRequestOps(requests []*etcdserverpb.RequestOp) ([]Request, error) {
+	result := make([]Request, 0, len(requests))
+	for _, request := range requests {
+		var op Request
+		if deleteRequest := request.GetRequestDeleteRange(); deleteRequest != nil {
+			op = deserializeDeleteRequest(deleteRequest)
+		} else if putRequest := request.GetRequestPut(); putRequest != nil {
+			op = deserializePutRequest(putRequest)
+		} else if rangeRequest := request.GetRequestRange(); rangeRequest != nil {
+			op = deserializeRangeRequest(rangeRequest)
+		} else if txnRequest := request.GetRequestTxn(); txnRequest != nil {
+			txn, err := deserializeTxnRequest(txnRequest)
+			if err != nil {
+				return nil, err
+			}
+			op = txn
+		} else {
+			return nil, fmt.Errorf("%w: %T", ErrUnknownRequest, request.GetRequest())
+		}
+		result = append(result, op)
 	}
-	return result
+	return result, nil
+}
+
+func deserializeTxnRequest(request *etcdserverpb.TxnRequest) (*TxnRequest, error) {
+	if request == nil {
+		return nil, nil
+	}
+	result := &TxnRequest{
+		Compare: make([]Compare, 0, len(request.Compare)),
+	}
+	for _, compare := range request.Compare {
+		result.Compare = append(result.Compare, deserializeCompare(compare))
+	}
+	var err error
+	if result.Success, err = deserializeRequestOps(request.Success); err != nil {
+		return nil, err
+	}
+	if result.Failure, err = deserializeRequestOps(request.Failure); err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
 type TxnResponse struct {
@@ -70,39 +137,177 @@ func (response TxnResponse) IsWrite() bool {
 	return result
 }
 
-func deserializeRequestOp(response *etcdserverpb.ResponseOp) Response {
+func deserializeRequestOp(response *etcdserverpb.ResponseOp) (Response, error) {
 	if deleteResponse := response.GetResponseDeleteRange(); deleteResponse != nil {
-		return deserializeDeleteResponse(deleteResponse)
+		return deserializeDeleteResponse(deleteResponse), nil
 	} else if putResponse := response.GetResponsePut(); putResponse != nil {
-		return deserializePutResponse(putResponse)
+		return deserializePutResponse(putResponse), nil
 	} else if rangeResponse := response.GetResponseRange(); rangeResponse != nil {
-		return deserializeRangeResponse(rangeResponse)
+		return deserializeRangeResponse(rangeResponse), nil
 	} else if txnResponse := response.GetResponseTxn(); txnResponse != nil {
 		return deserializeTxnResponse(txnResponse)
 	} else {
-		panic("unknown response type")
+		return nil, fmt.Errorf("%w: %T", ErrUnknownResponse, response.GetResponse())
 	}
 }
 
-func deserializeTxnResponse(response *etcdserverpb.TxnResponse) *TxnResponse {
+func deserializeTxnResponse(response *etcdserverpb.TxnResponse) (*TxnResponse, error) {
 	if response == nil {
-		return nil
+		return nil, nil
 	}
 	result := &TxnResponse{
-		Revision:  response.Header.Revision,
+		Revision:  response.GetHeader().GetRevision(),
 		Succeeded: response.Succeeded,
 		Responses: make([]Response, 0, len(response.Responses)),
 	}
 	for _, response := range response.Responses {
-		result.Responses = append(result.Responses, deserializeRequestOp(response))
+		op, err := deserializeRequestOp(response)
+		if err != nil {
+			return nil, err
+		}
+		result.Responses = append(result.Responses, op)
 	}
-	return result
+	return result, nil
+}
+
+func serializeResponseOp(response Response) (*etcdserverpb.ResponseOp, error) {
+	switch r := response.(type) {
+	case *DeleteResponse:
+		return &etcdserverpb.ResponseOp{Response: &etcdserverpb.ResponseOp_ResponseDeleteRange{ResponseDeleteRange: serializeDeleteResponse(r)}}, nil
+	case *PutResponse:
+		return &etcdserverpb.ResponseOp{Response: &etcdserverpb.ResponseOp_ResponsePut{ResponsePut: serializePutResponse(r)}}, nil
+	case *RangeResponse:
+		return &etcdserverpb.ResponseOp{Response: &etcdserverpb.ResponseOp_ResponseRange{ResponseRange: serializeRangeResponse(r)}}, nil
+	case *TxnResponse:
+		txn, err := serializeTxnResponse(r)
+		if err != nil {
+			return nil, err
+		}
+		return &etcdserverpb.ResponseOp{Response: &etcdserverpb.ResponseOp_ResponseTxn{ResponseTxn: txn}}, nil
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnknownResponse, response)
+	}
+}
+
+func serializeTxnResponse(response *TxnResponse) (*etcdserverpb.TxnResponse, error) {
+	if response == nil {
+		return nil, nil
+	}
+	result := &etcdserverpb.TxnResponse{
+		Header:    &etcdserverpb.ResponseHeader{Revision: response.Revision},
+		Succeeded: response.Succeeded,
+		Responses: make([]*etcdserverpb.ResponseOp, 0, len(response.Responses)),
+	}
+	for _, response := range response.Responses {
+		op, err := serializeResponseOp(response)
+		if err != nil {
+			return nil, err
+		}
+		result.Responses = append(result.Responses, op)
+	}
+	return result, nil
+}
+
+func Txn(ctx context.Context, kv KV, request *TxnRequest) (*TxnResponse, error) {
+	if err := Validate(request); err != nil {
+		return nil, err
+	}
+	serialized, err := serializeTxnRequest(request)
+	if err != nil {
+		return nil, err
+	}
+	response, err := kv.Txn(ctx, serialized)
+	if err != nil {
+		return nil, err
+	}
+	return deserializeTxnResponse(response)
+}
+
+var ErrInvalidTxn = errors.New("etcd: invalid txn")
+
+// TxnBuilder assembles a TxnRequest in the If, Then, Else order; repeated
+// calls of the same clause append to it. Misuse is recorded and reported by
+// Build instead of panicking.
+type TxnBuilder struct {
+	request TxnRequest
+	err     error
+
+	cthen, celse bool
+}
+
+func NewTxn() *TxnBuilder {
+	return &TxnBuilder{}
+}
+
+func (b *TxnBuilder) If(compares ...Compare) *TxnBuilder {
+	switch {
+	case b.cthen:
+		b.fail("If called after Then")
+	case b.celse:
+		b.fail("If called after Else")
+	}
+	b.request.Compare = append(b.request.Compare, compares...)
+	return b
+}
+
+func (b *TxnBuilder) Then(ops ...Request) *TxnBuilder {
+	if b.celse {
+		b.fail("Then called after Else")
+	}
+	b.cthen = true
+	b.request.Success = append(b.request.Success, ops...)
+	return b
+}
+
+func (b *TxnBuilder) Else(ops ...Request) *TxnBuilder {
+	b.celse = true
+	b.request.Failure = append(b.request.Failure, ops...)
+	return b
+}
+
+// ThenTxn appends a nested txn to the success branch.
+func (b *TxnBuilder) ThenTxn(nested *TxnBuilder) *TxnBuilder {
+	return b.Then(b.nested(nested))
+}
+
+// ElseTxn appends a nested txn to the failure branch.
+func (b *TxnBuilder) ElseTxn(nested *TxnBuilder) *TxnBuilder {
+	return b.Else(b.nested(nested))
+}
+
+func (b *TxnBuilder) nested(nested *TxnBuilder) Request {
+	request, err := nested.Build()
+	if err != nil && b.err == nil {
+		b.err = fmt.Errorf("nested txn: %w", err)
+	}
+	return request
+}
+
+func (b *TxnBuilder) fail(reason string) {
+	if b.err == nil {
+		b.err = fmt.Errorf("%w: %s", ErrInvalidTxn, reason)
+	}
+}
+
+func (b *TxnBuilder) Build() (*TxnRequest, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	request := &TxnRequest{
+		Compare: append([]Compare{}, b.request.Compare...),
+		Success: append([]Request{}, b.request.Success...),
+		Failure: append([]Request{}, b.request.Failure...),
+	}
+	if err := Validate(request); err != nil {
+		return nil, err
+	}
+	return request, nil
 }
 
-func Txn(ctx context.Context, client *Client, request *TxnRequest) (*TxnResponse, error) {
-	response, err := client.Txn(ctx, serializeTxnRequest(request))
+func (b *TxnBuilder) Commit(ctx context.Context, kv KV) (*TxnResponse, error) {
+	request, err := b.Build()
 	if err != nil {
 		return nil, err
 	}
-	return deserializeTxnResponse(response), nil
+	return Txn(ctx, kv, request)
 }
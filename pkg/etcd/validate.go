@@ -0,0 +1,88 @@
+package etcd
+
+import (
+	"errors"
+	"fmt"
+
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+)
+
+// Errors found by Validate before a request is sent. ErrEmptyKey and
+// ErrTooManyOps are the errors an etcd server answers with for the same
+// requests, so callers match them the same way either side rejects. Invalid
+// txn ops are reported wrapping ErrInvalidTxn, as the txn builder reports them.
+var (
+	ErrEmptyKey        = rpctypes.ErrGRPCEmptyKey
+	ErrTooManyOps      = rpctypes.ErrGRPCTooManyOps
+	ErrCompactInTxn    = fmt.Errorf("%w: compact is not allowed", ErrInvalidTxn)
+	ErrUnknownRequest  = errors.New("etcd: unknown request type")
+	ErrUnknownResponse = errors.New("etcd: unknown response type")
+)
+
+// MaxTxnOps is the limit on compares and ops per txn branch, the default of
+// the etcd --max-txn-ops flag.
+var MaxTxnOps = 128
+
+// Validate checks a request for the mistakes that etcd rejects regardless of
+// the data, so that they fail without a round trip.
+func Validate(request Request) error {
+	switch r := request.(type) {
+	case *CompactRequest:
+		if r != nil {
+			return nil
+		}
+	case *DeleteRequest:
+		if r != nil {
+			return validateKey(r.Key)
+		}
+	case *PutRequest:
+		if r != nil {
+			return validateKey(r.Key)
+		}
+	case *RangeRequest:
+		if r != nil {
+			return validateKey(r.Key)
+		}
+	case *TxnRequest:
+		if r != nil {
+			return validateTxnRequest(r)
+		}
+	case nil:
+	default:
+		return fmt.Errorf("%w: %T", ErrUnknownRequest, request)
+	}
+	return fmt.Errorf("%w: nil %T", ErrUnknownRequest, request)
+}
+
+func validateKey(key string) error {
+	if len(key) == 0 {
+		return ErrEmptyKey
+	}
+	return nil
+}
+
+func validateTxnRequest(request *TxnRequest) error {
+	if len(request.Compare) > MaxTxnOps || len(request.Success) > MaxTxnOps || len(request.Failure) > MaxTxnOps {
+		return ErrTooManyOps
+	}
+	for _, compare := range request.Compare {
+		if err := validateCompare(compare); err != nil {
+			return err
+		}
+	}
+	for _, ops := range [][]Request{request.Success, request.Failure} {
+		for _, op := range ops {
+			if _, ok := op.(*CompactRequest); ok {
+				return ErrCompactInTxn
+			}
+			err := Validate(op)
+			if errors.Is(err, ErrUnknownRequest) && !errors.Is(err, ErrInvalidTxn) {
+				return fmt.Errorf("%w: %w", ErrInvalidTxn, err)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
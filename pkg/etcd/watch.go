@@ -0,0 +1,135 @@
+package etcd
+
+import (
+	"context"
+	"fmt"
+
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+)
+
+type WatchRequest struct {
+	Key            string `json:"key,omitempty"`
+	RangeEnd       string `json:"range_end,omitempty"`
+	StartRevision  int64  `json:"start_revision,omitempty"`
+	PrevKv         bool   `json:"prev_kv,omitempty"`
+	ProgressNotify bool   `json:"progress_notify,omitempty"`
+	NoPut          bool   `json:"no_put,omitempty"`
+	NoDelete       bool   `json:"no_delete,omitempty"`
+}
+
+func serializeWatchRequest(request *WatchRequest) *etcdserverpb.WatchRequest {
+	if request == nil {
+		return nil
+	}
+	var filters []etcdserverpb.WatchCreateRequest_FilterType
+	if request.NoPut {
+		filters = append(filters, etcdserverpb.WatchCreateRequest_NOPUT)
+	}
+	if request.NoDelete {
+		filters = append(filters, etcdserverpb.WatchCreateRequest_NODELETE)
+	}
+	return &etcdserverpb.WatchRequest{
+		RequestUnion: &etcdserverpb.WatchRequest_CreateRequest{
+			CreateRequest: &etcdserverpb.WatchCreateRequest{
+				Key:            []byte(request.Key),
+				RangeEnd:       []byte(request.RangeEnd),
+				StartRevision:  request.StartRevision,
+				ProgressNotify: request.ProgressNotify,
+				Filters:        filters,
+				PrevKv:         request.PrevKv,
+			},
+		},
+	}
+}
+
+type Event struct {
+	Type   mvccpb.Event_EventType `json:"type,omitempty"`
+	Kv     *KeyValue              `json:"kv,omitempty"`
+	PrevKv *KeyValue              `json:"prev_kv,omitempty"`
+}
+
+func deserializeEvent(event *mvccpb.Event) *Event {
+	if event == nil {
+		return nil
+	}
+	return &Event{
+		Type:   event.Type,
+		Kv:     deserializeKeyValue(event.Kv),
+		PrevKv: deserializeKeyValue(event.PrevKv),
+	}
+}
+
+type WatchResponse struct {
+	Revision        int64    `json:"revision,omitempty"`
+	Events          []*Event `json:"events"`
+	CompactRevision int64    `json:"compact_revision,omitempty"`
+	Canceled        bool     `json:"canceled,omitempty"`
+	CancelReason    string   `json:"cancel_reason,omitempty"`
+	// Err is the error the watch stream failed with.
+	Err error `json:"-"`
+}
+
+func deserializeWatchResponse(response *etcdserverpb.WatchResponse) *WatchResponse {
+	if response == nil {
+		return nil
+	}
+	events := make([]*Event, 0, len(response.Events))
+	for _, event := range response.Events {
+		events = append(events, deserializeEvent(event))
+	}
+	return &WatchResponse{
+		Revision:        response.GetHeader().GetRevision(),
+		Events:          events,
+		CompactRevision: response.CompactRevision,
+		Canceled:        response.Canceled,
+		CancelReason:    response.CancelReason,
+	}
+}
+
+// Watch opens a watch stream for a single key range. The returned channel
+// receives every response but the creation one and is closed once the watch
+// is canceled, the stream fails or ctx is done. A failed stream is reported
+// by a last canceled response carrying the error.
+func Watch(ctx context.Context, w Watcher, request *WatchRequest) (<-chan *WatchResponse, error) {
+	stream, err := w.Watch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.Send(serializeWatchRequest(request)); err != nil {
+		return nil, err
+	}
+	created, err := stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	if created.Canceled {
+		return nil, fmt.Errorf("etcd: watch canceled: %s", created.CancelReason)
+	}
+
+	ch := make(chan *WatchResponse)
+	go func() {
+		defer close(ch)
+		for {
+			response, err := stream.Recv()
+			if err != nil {
+				if ctx.Err() == nil {
+					select {
+					case ch <- &WatchResponse{Canceled: true, CancelReason: err.Error(), Err: err}:
+					case <-ctx.Done():
+					}
+				}
+				return
+			}
+			select {
+			case ch <- deserializeWatchResponse(response):
+			case <-ctx.Done():
+				return
+			}
+			if response.Canceled {
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
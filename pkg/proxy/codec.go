@@ -0,0 +1,35 @@
+package proxy
+
+import (
+	"fmt"
+)
+
+// frame is a message passed through the proxy without decoding.
+type frame struct {
+	payload []byte
+}
+
+// codec hands the raw bytes of messages over, so the proxy forwards every
+// service of the target without knowing its types.
+type codec struct{}
+
+func (codec) Marshal(v any) ([]byte, error) {
+	f, ok := v.(*frame)
+	if !ok {
+		return nil, fmt.Errorf("proxy: cannot marshal %T", v)
+	}
+	return f.payload, nil
+}
+
+func (codec) Unmarshal(data []byte, v any) error {
+	f, ok := v.(*frame)
+	if !ok {
+		return fmt.Errorf("proxy: cannot unmarshal into %T", v)
+	}
+	f.payload = append([]byte(nil), data...)
+	return nil
+}
+
+func (codec) Name() string {
+	return "proto"
+}
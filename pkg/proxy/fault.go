@@ -0,0 +1,359 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type FaultKind string
+
+const (
+	// FaultLatency delays every message sent to the target by Latency.
+	FaultLatency FaultKind = "latency"
+	// FaultUnavailable fails calls with codes.Unavailable without forwarding
+	// them.
+	FaultUnavailable FaultKind = "unavailable"
+	// FaultBlackhole never answers calls, they hang until the caller gives up.
+	FaultBlackhole FaultKind = "blackhole"
+	// FaultPause holds the messages of calls in flight, streams included, in
+	// both directions until the fault is cleared.
+	FaultPause FaultKind = "pause"
+	// FaultDrop closes new connections as soon as they are accepted.
+	FaultDrop FaultKind = "drop"
+	// FaultReset closes every open connection once when injected. It stays
+	// active for no time.
+	FaultReset FaultKind = "reset"
+)
+
+var faultKinds = []FaultKind{FaultLatency, FaultUnavailable, FaultBlackhole, FaultPause, FaultDrop, FaultReset}
+
+type Fault struct {
+	Kind    FaultKind
+	Latency time.Duration
+	// Rate is the share of messages, calls or connections the fault applies
+	// to, 0 means all of them. FaultPause and FaultReset ignore it.
+	Rate float64
+	// Methods limits the fault to calls of these full methods, e.g.
+	// "/etcdserverpb.KV/Range". FaultDrop and FaultReset ignore it.
+	Methods []string
+}
+
+func (f Fault) String() string {
+	s := string(f.Kind)
+	if f.Kind == FaultLatency {
+		s += ":" + f.Latency.String()
+	}
+	if f.Rate > 0 {
+		s += "*" + strconv.FormatFloat(f.Rate, 'g', -1, 64)
+	}
+	return s
+}
+
+// healthService is spared from faults of calls, otherwise clients checking
+// health would stop using the connection instead of seeing the fault.
+const healthService = "/grpc.health.v1.Health/"
+
+func (f Fault) applies(method string) bool {
+	if f.Kind != FaultDrop && strings.HasPrefix(method, healthService) {
+		return false
+	}
+	if f.Kind != FaultDrop && len(f.Methods) != 0 && !slices.Contains(f.Methods, method) {
+		return false
+	}
+	return f.Kind == FaultPause || f.Rate <= 0 || rand.Float64() < f.Rate
+}
+
+// faults is the set of active faults, at most one of each kind.
+type faults struct {
+	mu      sync.Mutex
+	active  map[FaultKind]Fault
+	changed chan struct{}
+	conns   map[net.Conn]struct{}
+}
+
+func (fs *faults) init() {
+	fs.active = make(map[FaultKind]Fault)
+	fs.changed = make(chan struct{})
+	fs.conns = make(map[net.Conn]struct{})
+}
+
+// get returns the active fault of kind if it applies to a call of method, and
+// a channel closed on the next change of the active faults.
+func (fs *faults) get(kind FaultKind, method string) (Fault, bool, <-chan struct{}) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	f, ok := fs.active[kind]
+	return f, ok && f.applies(method), fs.changed
+}
+
+func (fs *faults) notify() {
+	close(fs.changed)
+	fs.changed = make(chan struct{})
+}
+
+// Inject activates f in place of the active fault of the same kind.
+func (p *Proxy) Inject(f Fault) error {
+	if !slices.Contains(faultKinds, f.Kind) {
+		return fmt.Errorf("proxy: unknown fault %q", f.Kind)
+	}
+	if f.Kind == FaultReset {
+		p.Reset()
+		return nil
+	}
+	p.faults.mu.Lock()
+	defer p.faults.mu.Unlock()
+	p.faults.active[f.Kind] = f
+	p.faults.notify()
+	return nil
+}
+
+// Clear deactivates the faults of kinds, or every fault if none is given.
+// Calls held by a blackhole stay lost.
+func (p *Proxy) Clear(kinds ...FaultKind) {
+	p.faults.mu.Lock()
+	defer p.faults.mu.Unlock()
+	if len(kinds) == 0 {
+		clear(p.faults.active)
+	}
+	for _, kind := range kinds {
+		delete(p.faults.active, kind)
+	}
+	p.faults.notify()
+}
+
+// Faults returns the active faults.
+func (p *Proxy) Faults() []Fault {
+	p.faults.mu.Lock()
+	defer p.faults.mu.Unlock()
+	var active []Fault
+	for _, kind := range faultKinds {
+		if f, ok := p.faults.active[kind]; ok {
+			active = append(active, f)
+		}
+	}
+	return active
+}
+
+// Reset closes every open connection to the proxy, failing the calls in
+// flight over them.
+func (p *Proxy) Reset() {
+	p.faults.mu.Lock()
+	conns := make([]net.Conn, 0, len(p.faults.conns))
+	for conn := range p.faults.conns {
+		conns = append(conns, conn)
+	}
+	p.faults.mu.Unlock()
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
+
+// intercept applies the faults decided once per call before it is forwarded.
+func (p *Proxy) intercept(ctx context.Context, method string) error {
+	if _, ok, _ := p.faults.get(FaultUnavailable, method); ok {
+		return status.Error(codes.Unavailable, "proxy: injected unavailable")
+	}
+	if _, ok, _ := p.faults.get(FaultBlackhole, method); ok {
+		<-ctx.Done()
+		return status.FromContextError(ctx.Err()).Err()
+	}
+	return nil
+}
+
+// delay applies the faults of every forwarded message: it waits out a pause
+// and, for messages sent to the target, the injected latency.
+func (p *Proxy) delay(ctx context.Context, method string, toTarget bool) error {
+	for {
+		_, paused, changed := p.faults.get(FaultPause, method)
+		if !paused {
+			break
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+	if !toTarget {
+		return nil
+	}
+	if f, ok, _ := p.faults.get(FaultLatency, method); ok && f.Latency > 0 {
+		timer := time.NewTimer(f.Latency)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+	return nil
+}
+
+// listener tracks accepted connections for Reset and drops them under
+// FaultDrop.
+type listener struct {
+	net.Listener
+	faults *faults
+}
+
+func (lis *listener) Accept() (net.Conn, error) {
+	for {
+		conn, err := lis.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if _, drop, _ := lis.faults.get(FaultDrop, ""); drop {
+			conn.Close()
+			continue
+		}
+		lis.faults.mu.Lock()
+		lis.faults.conns[conn] = struct{}{}
+		lis.faults.mu.Unlock()
+		return &trackedConn{Conn: conn, faults: lis.faults}, nil
+	}
+}
+
+type trackedConn struct {
+	net.Conn
+	faults *faults
+}
+
+func (conn *trackedConn) Close() error {
+	conn.faults.mu.Lock()
+	delete(conn.faults.conns, conn.Conn)
+	conn.faults.mu.Unlock()
+	return conn.Conn.Close()
+}
+
+// Step is a fault active for Duration from At after a schedule starts. A
+// zero Duration keeps the fault until the schedule is canceled.
+type Step struct {
+	At       time.Duration
+	Duration time.Duration
+	Fault    Fault
+}
+
+// ParseSchedule parses comma separated steps of the form
+// kind[:latency][*rate]@at[+duration], e.g.
+// "latency:50ms@10s+20s,unavailable*0.5@1m+10s,reset@2m".
+func ParseSchedule(s string) ([]Step, error) {
+	var steps []Step
+	for _, text := range strings.Split(s, ",") {
+		if text = strings.TrimSpace(text); text == "" {
+			continue
+		}
+		step, err := parseStep(text)
+		if err != nil {
+			return nil, fmt.Errorf("proxy: invalid fault step %q: %w", text, err)
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+func parseStep(text string) (Step, error) {
+	var step Step
+	fault, when, ok := strings.Cut(text, "@")
+	if !ok {
+		return step, fmt.Errorf("missing @")
+	}
+	at, duration, hasDuration := strings.Cut(when, "+")
+	var err error
+	if step.At, err = time.ParseDuration(at); err != nil {
+		return step, err
+	}
+	if hasDuration {
+		if step.Duration, err = time.ParseDuration(duration); err != nil {
+			return step, err
+		}
+	}
+	fault, rate, hasRate := strings.Cut(fault, "*")
+	if hasRate {
+		if step.Fault.Rate, err = strconv.ParseFloat(rate, 64); err != nil {
+			return step, err
+		}
+	}
+	kind, latency, hasLatency := strings.Cut(fault, ":")
+	step.Fault.Kind = FaultKind(kind)
+	if !slices.Contains(faultKinds, step.Fault.Kind) {
+		return step, fmt.Errorf("unknown fault %q", kind)
+	}
+	if hasLatency != (step.Fault.Kind == FaultLatency) {
+		return step, fmt.Errorf("only latency takes a duration")
+	}
+	if hasLatency {
+		if step.Fault.Latency, err = time.ParseDuration(latency); err != nil {
+			return step, err
+		}
+	}
+	if step.At < 0 || step.Duration < 0 || step.Fault.Rate < 0 || step.Fault.Rate > 1 {
+		return step, fmt.Errorf("negative times and rates out of [0, 1] are not allowed")
+	}
+	return step, nil
+}
+
+// RunSchedule injects and clears the faults of steps on time until the last
+// step ends, or until ctx is done if a step lasts indefinitely, then clears
+// every fault.
+func (p *Proxy) RunSchedule(ctx context.Context, steps []Step) error {
+	type event struct {
+		at     time.Duration
+		inject bool
+		fault  Fault
+	}
+	var events []event
+	var end time.Duration
+	indefinite := false
+	for _, step := range steps {
+		events = append(events, event{at: step.At, inject: true, fault: step.Fault})
+		if step.Duration > 0 {
+			events = append(events, event{at: step.At + step.Duration, fault: step.Fault})
+		} else if step.Fault.Kind != FaultReset {
+			indefinite = true
+		}
+		end = max(end, step.At+step.Duration)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].at < events[j].at })
+	defer p.Clear()
+
+	start := time.Now()
+	for _, e := range events {
+		timer := time.NewTimer(time.Until(start.Add(e.at)))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+		if !e.inject {
+			p.Clear(e.fault.Kind)
+			continue
+		}
+		if err := p.Inject(e.fault); err != nil {
+			return err
+		}
+	}
+	if indefinite {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	timer := time.NewTimer(time.Until(start.Add(end)))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,210 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// Call is a call forwarded by the proxy. Request and Response hold the first
+// message of each direction as it was sent over the wire.
+type Call struct {
+	Method    string
+	Start     time.Time
+	Duration  time.Duration
+	Request   []byte
+	Response  []byte
+	Requests  int
+	Responses int
+	Err       error
+}
+
+// Unary reports whether the call exchanged a single message each way.
+func (call *Call) Unary() bool {
+	return call.Requests == 1 && call.Responses <= 1
+}
+
+type Option func(*Proxy)
+
+// WithObserver makes the proxy pass every finished call to observe. It is
+// called concurrently from the handlers of the calls.
+func WithObserver(observe func(Call)) Option {
+	return func(p *Proxy) {
+		p.observe = observe
+	}
+}
+
+// Proxy is a transparent gRPC proxy forwarding every call to a target
+// connection, streams included. Faults injected into it apply to the calls
+// it forwards from then on.
+type Proxy struct {
+	conn    *grpc.ClientConn
+	observe func(Call)
+	server  *grpc.Server
+	faults  faults
+}
+
+func New(conn *grpc.ClientConn, opts ...Option) *Proxy {
+	p := &Proxy{conn: conn}
+	p.faults.init()
+	for _, opt := range opts {
+		opt(p)
+	}
+	p.server = grpc.NewServer(
+		grpc.ForceServerCodec(codec{}),
+		grpc.UnknownServiceHandler(p.handle),
+		grpc.WaitForHandlers(true),
+	)
+	return p
+}
+
+func (p *Proxy) Serve(lis net.Listener) error {
+	return p.server.Serve(&listener{Listener: lis, faults: &p.faults})
+}
+
+// Stop closes the listeners, cancels the calls in flight and waits for their
+// handlers to return, so that no call is observed after it.
+func (p *Proxy) Stop() {
+	p.server.Stop()
+}
+
+func (p *Proxy) handle(_ any, serverStream grpc.ServerStream) error {
+	method, ok := grpc.MethodFromServerStream(serverStream)
+	if !ok {
+		return status.Error(codes.Internal, "proxy: method is unknown")
+	}
+	ctx, cancel := context.WithCancel(serverStream.Context())
+	defer cancel()
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		ctx = metadata.NewOutgoingContext(ctx, md.Copy())
+	}
+
+	call := &observedCall{Call: Call{Method: method, Start: time.Now()}}
+	err := p.intercept(ctx, method)
+	if err == nil {
+		err = p.forward(ctx, cancel, method, serverStream, call)
+	}
+	if p.observe != nil {
+		observed := call.snapshot()
+		observed.Duration = time.Since(observed.Start)
+		observed.Err = err
+		p.observe(observed)
+	}
+	return err
+}
+
+func (p *Proxy) forward(ctx context.Context, cancel context.CancelFunc, method string, serverStream grpc.ServerStream, call *observedCall) error {
+	clientStream, err := p.conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}, method, grpc.ForceCodec(codec{}))
+	if err != nil {
+		return err
+	}
+
+	requestErr := make(chan error, 1)
+	go func() {
+		requestErr <- p.forwardRequests(ctx, method, serverStream, clientStream, call)
+	}()
+	responseErr := make(chan error, 1)
+	go func() {
+		responseErr <- p.forwardResponses(ctx, method, clientStream, serverStream, call)
+	}()
+
+	for {
+		select {
+		case err := <-requestErr:
+			if err != nil {
+				// The caller is gone, there is nobody to answer to.
+				cancel()
+				return status.Error(codes.Canceled, err.Error())
+			}
+			requestErr = nil
+		case err := <-responseErr:
+			serverStream.SetTrailer(clientStream.Trailer())
+			return err
+		}
+	}
+}
+
+// forwardRequests sends the messages of the caller to the target until the
+// caller closes its side of the stream.
+func (p *Proxy) forwardRequests(ctx context.Context, method string, src grpc.ServerStream, dst grpc.ClientStream, call *observedCall) error {
+	for {
+		var f frame
+		if err := src.RecvMsg(&f); errors.Is(err, io.EOF) {
+			return dst.CloseSend()
+		} else if err != nil {
+			return err
+		}
+		call.request(f.payload)
+		if err := p.delay(ctx, method, true); err != nil {
+			return err
+		}
+		if err := dst.SendMsg(&f); err != nil {
+			// The target failed the call, forwardResponses gets its status.
+			return nil
+		}
+	}
+}
+
+// forwardResponses sends the header and messages of the target to the caller
+// and returns the status the target finished the call with.
+func (p *Proxy) forwardResponses(ctx context.Context, method string, src grpc.ClientStream, dst grpc.ServerStream, call *observedCall) error {
+	header, err := src.Header()
+	if err != nil {
+		return err
+	}
+	if err := dst.SendHeader(header); err != nil {
+		return err
+	}
+	for {
+		var f frame
+		if err := src.RecvMsg(&f); errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		call.response(f.payload)
+		if err := p.delay(ctx, method, false); err != nil {
+			return err
+		}
+		if err := dst.SendMsg(&f); err != nil {
+			return err
+		}
+	}
+}
+
+type observedCall struct {
+	mu sync.Mutex
+	Call
+}
+
+func (call *observedCall) request(payload []byte) {
+	call.mu.Lock()
+	defer call.mu.Unlock()
+	if call.Requests == 0 {
+		call.Call.Request = payload
+	}
+	call.Requests++
+}
+
+func (call *observedCall) response(payload []byte) {
+	call.mu.Lock()
+	defer call.mu.Unlock()
+	if call.Responses == 0 {
+		call.Call.Response = payload
+	}
+	call.Responses++
+}
+
+func (call *observedCall) snapshot() Call {
+	call.mu.Lock()
+	defer call.mu.Unlock()
+	return call.Call
+}
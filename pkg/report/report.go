@@ -7,7 +7,12 @@ import (
 
 type Result struct {
 	TotalTime time.Duration
+	Retries   int
 	Err       error
+	// Anomalies are the kinds of integrity anomalies found in the response.
+	Anomalies []string
+	// Deleted counts the keys deleted by the request.
+	Deleted int64
 }
 
 type Percentile struct {
@@ -22,8 +27,35 @@ type Stats struct {
 	Slowest     time.Duration
 	Average     time.Duration
 	RPS         float64
+	Retries     int
 	Percentiles []Percentile
 	Errors      map[string]int
+	Anomalies   map[string]int
+	// Deleted counts the keys deleted by successful requests and DPS is their
+	// rate.
+	Deleted int64
+	DPS     float64
+	// Series is the load over time if the report was created WithSeries.
+	Series []Sample `json:",omitempty"`
+}
+
+// Sample is the load of an interval of a report: the offered requests per
+// second, the requests per second that succeeded and the failed requests.
+type Sample struct {
+	At       time.Duration
+	Offered  float64
+	Achieved float64
+	Errors   int
+}
+
+type Option func(*report)
+
+// WithSeries samples the load every interval, offered returns the rate of
+// requests offered at a time.
+func WithSeries(interval time.Duration, offered func(time.Time) float64) Option {
+	return func(r *report) {
+		r.interval, r.offered = interval, offered
+	}
 }
 
 type Report interface {
@@ -32,17 +64,24 @@ type Report interface {
 }
 
 type report struct {
-	results chan Result
-	stats   Stats
+	results  chan Result
+	stats    Stats
+	interval time.Duration
+	offered  func(time.Time) float64
 }
 
-func NewReport(totalClients uint) Report {
-	return &report{
+func NewReport(totalClients uint, opts ...Option) Report {
+	r := &report{
 		results: make(chan Result, totalClients),
 		stats: Stats{
-			Errors: make(map[string]int),
+			Errors:    make(map[string]int),
+			Anomalies: make(map[string]int),
 		},
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
 func (r *report) Results() chan<- Result {
@@ -62,14 +101,44 @@ func (r *report) Run() <-chan Stats {
 func (r *report) processResults() {
 	start := time.Now()
 	latencies := []time.Duration{}
+	var series []Sample
 	for res := range r.results {
+		var sample *Sample
+		if r.interval > 0 {
+			i := int(time.Since(start) / r.interval)
+			for len(series) <= i {
+				series = append(series, Sample{At: time.Duration(len(series)) * r.interval})
+			}
+			sample = &series[i]
+		}
+		r.stats.Retries += res.Retries
+		for _, anomaly := range res.Anomalies {
+			r.stats.Anomalies[anomaly]++
+		}
 		if res.Err != nil {
 			r.stats.Errors[res.Err.Error()]++
+			if sample != nil {
+				sample.Errors++
+			}
 			continue
 		}
+		if sample != nil {
+			sample.Achieved++
+		}
+		r.stats.Deleted += res.Deleted
 		latencies = append(latencies, res.TotalTime)
 	}
 	r.stats.TotalTime = time.Since(start)
+	for i := range series {
+		// The offered rate is averaged and the last interval may be partial.
+		const points = 10
+		length := min(r.interval, r.stats.TotalTime-series[i].At)
+		for j := range points {
+			series[i].Offered += r.offered(start.Add(series[i].At+length*time.Duration(2*j+1)/(2*points))) / points
+		}
+		series[i].Achieved /= length.Seconds()
+	}
+	r.stats.Series = series
 
 	if len(latencies) == 0 {
 		return
@@ -86,9 +155,20 @@ func (r *report) processResults() {
 	r.stats.Average = time.Duration(int(avgTotal.Nanoseconds()) / len(latencies))
 
 	r.stats.RPS = float64(len(latencies)) / r.stats.TotalTime.Seconds()
+	r.stats.DPS = float64(r.stats.Deleted) / r.stats.TotalTime.Seconds()
 
+	r.stats.Percentiles = Percentiles(latencies)
+}
+
+// Percentiles returns the latency percentiles of sorted latencies.
+func Percentiles(latencies []time.Duration) []Percentile {
+	if len(latencies) == 0 {
+		return nil
+	}
+	var percentiles []Percentile
 	for _, percentile := range []float64{10, 25, 50, 75, 90, 95, 99, 99.9} {
 		i := int(float64(len(latencies)) * percentile / 100.0)
-		r.stats.Percentiles = append(r.stats.Percentiles, Percentile{Percentile: percentile, Latency: latencies[i]})
+		percentiles = append(percentiles, Percentile{Percentile: percentile, Latency: latencies[i]})
 	}
+	return percentiles
 }
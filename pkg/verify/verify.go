@@ -0,0 +1,404 @@
+package verify
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+
+	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
+)
+
+// Anomaly kinds found in responses.
+const (
+	// LostWrite is a read missing a write acknowledged before the read began.
+	LostWrite = "lost write"
+	// StaleRead is a read older than a revision observed before it began.
+	StaleRead = "stale read"
+	// ValueMismatch is a key read at the revision of an acknowledged write
+	// with another value.
+	ValueMismatch = "value mismatch"
+	// InvalidVersion is a key whose version does not count its writes.
+	InvalidVersion = "invalid version"
+	// InvalidRevision is a key revised after the revision of the response or
+	// created after it was modified.
+	InvalidRevision = "invalid revision"
+	// NonMonotonicRevision is a response of a session older than a previous
+	// one, or a write of a session that did not advance its revision.
+	NonMonotonicRevision = "non-monotonic revision"
+)
+
+type Anomaly struct {
+	Kind   string
+	Key    string
+	Detail string
+}
+
+func (a Anomaly) Error() string {
+	return fmt.Sprintf("verify: %s of key %q: %s", a.Kind, a.Key, a.Detail)
+}
+
+// write is the latest acknowledged write of a key, ordered by revision.
+type write struct {
+	revision   int64
+	value      string
+	valueKnown bool
+	deleted    bool
+	// mayBeDeleted is set when a failed delete may have removed the key
+	// after the write.
+	mayBeDeleted bool
+	// seq orders the acknowledgement against the start of checks.
+	seq uint64
+}
+
+// observation is the latest state of a key seen by a read.
+type observation struct {
+	createRevision int64
+	modRevision    int64
+	version        int64
+	seq            uint64
+}
+
+type keyState struct {
+	write       write
+	observation observation
+}
+
+// rangeDelete is a delete kept for reads of absent keys: a delete of a range
+// may have removed keys whose writes were acknowledged after it, and an
+// uncertain delete failed and may or may not have been applied. The revision
+// of an uncertain delete is the latest one observed when it failed, it is
+// taken to follow the writes up to it.
+type rangeDelete struct {
+	key, rangeEnd string
+	revision      int64
+	uncertain     bool
+	seq           uint64
+}
+
+// covers reports whether the delete may have removed key between the
+// revisions after and before.
+func (d rangeDelete) covers(key string, after, before int64) bool {
+	if d.uncertain {
+		return inRange(key, d.key, d.rangeEnd) && d.revision >= after
+	}
+	return inRange(key, d.key, d.rangeEnd) && d.revision > after && d.revision <= before
+}
+
+func inRange(key, start, end string) bool {
+	return key >= start && (end == etcd.EmptyKey || key < end)
+}
+
+// Verifier tracks the acknowledged writes and observed reads of every key to
+// validate responses against them. The checks only flag what cannot happen
+// on a linearizable store: concurrent requests whose order is unknown are
+// given the benefit of the doubt.
+type Verifier struct {
+	mu             sync.Mutex
+	seq            uint64
+	maxRevision    int64
+	keys           map[string]*keyState
+	deletes        []rangeDelete
+	pendingDeletes int
+	// open counts the checks in flight by the revision observed when they
+	// began, which bounds the revisions of their writes.
+	open map[int64]int
+}
+
+func New() *Verifier {
+	return &Verifier{keys: make(map[string]*keyState), open: make(map[int64]int)}
+}
+
+func (v *Verifier) key(key string) *keyState {
+	state, ok := v.keys[key]
+	if !ok {
+		state = &keyState{}
+		v.keys[key] = state
+	}
+	return state
+}
+
+// Session checks the requests of a single client issued one after another.
+type Session struct {
+	verifier *Verifier
+	revision int64
+}
+
+func (v *Verifier) Session() *Session {
+	return &Session{verifier: v}
+}
+
+// Check is a request in flight.
+type Check struct {
+	session     *Session
+	request     etcd.Request
+	seq         uint64
+	maxRevision int64
+	deletes     bool
+}
+
+// Begin starts checking request, it must be called before request is sent.
+func (s *Session) Begin(request etcd.Request) *Check {
+	v := s.verifier
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	c := &Check{session: s, request: request, seq: v.seq, maxRevision: v.maxRevision, deletes: hasDeletes(request)}
+	if c.deletes {
+		v.pendingDeletes++
+	}
+	v.open[c.maxRevision]++
+	return c
+}
+
+// End validates the outcome of the request and records its writes and
+// reads. A failed request may or may not have been applied, so only its
+// deletes are remembered.
+func (c *Check) End(response etcd.Response, err error) []Anomaly {
+	v := c.session.verifier
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if c.deletes {
+		v.pendingDeletes--
+	}
+	if v.open[c.maxRevision]--; v.open[c.maxRevision] == 0 {
+		delete(v.open, c.maxRevision)
+	}
+	defer v.pruneDeletes()
+	v.seq++
+	if err != nil {
+		v.recordUncertainDeletes(c.request)
+		return nil
+	}
+
+	var anomalies []Anomaly
+	revision := response.GetRevision()
+	if revision < c.session.revision || (response.IsWrite() && revision <= c.session.revision) {
+		anomalies = append(anomalies, Anomaly{
+			Kind:   NonMonotonicRevision,
+			Detail: fmt.Sprintf("revision %d after %d", revision, c.session.revision),
+		})
+	}
+	if revision < c.maxRevision {
+		anomalies = append(anomalies, Anomaly{
+			Kind:   StaleRead,
+			Detail: fmt.Sprintf("revision %d after %d was observed", revision, c.maxRevision),
+		})
+	}
+	c.session.revision = max(c.session.revision, revision)
+	v.maxRevision = max(v.maxRevision, revision)
+	return c.check(anomalies, c.request, response, revision)
+}
+
+func (c *Check) check(anomalies []Anomaly, request etcd.Request, response etcd.Response, revision int64) []Anomaly {
+	v := c.session.verifier
+	switch r := request.(type) {
+	case *etcd.PutRequest:
+		state := v.key(r.Key)
+		if revision > state.write.revision {
+			state.write = write{revision: revision, value: r.Value, valueKnown: !r.IgnoreValue, seq: v.seq}
+		}
+		// A range delete acknowledged before the put may still follow it.
+		for _, d := range v.deletes {
+			switch {
+			case !d.covers(r.Key, state.write.revision, d.revision):
+			case d.uncertain:
+				state.write.mayBeDeleted = true
+			default:
+				state.write = write{revision: d.revision, deleted: true, seq: d.seq}
+			}
+		}
+	case *etcd.DeleteRequest:
+		resp, ok := response.(*etcd.DeleteResponse)
+		if !ok || resp.Deleted == 0 {
+			break
+		}
+		if r.RangeEnd == "" {
+			state := v.key(r.Key)
+			if revision > state.write.revision {
+				state.write = write{revision: revision, deleted: true, seq: v.seq}
+			}
+			break
+		}
+		v.deletes = append(v.deletes, rangeDelete{key: r.Key, rangeEnd: r.RangeEnd, revision: revision, seq: v.seq})
+		for key, state := range v.keys {
+			if inRange(key, r.Key, r.RangeEnd) && revision > state.write.revision {
+				state.write = write{revision: revision, deleted: true, seq: v.seq}
+			}
+		}
+	case *etcd.RangeRequest:
+		if resp, ok := response.(*etcd.RangeResponse); ok {
+			anomalies = c.checkRange(anomalies, r, resp, revision)
+		}
+	case *etcd.TxnRequest:
+		resp, ok := response.(*etcd.TxnResponse)
+		if !ok {
+			break
+		}
+		ops := r.Failure
+		if resp.Succeeded {
+			ops = r.Success
+		}
+		for i, op := range ops {
+			if i < len(resp.Responses) {
+				anomalies = c.check(anomalies, op, resp.Responses[i], revision)
+			}
+		}
+	}
+	return anomalies
+}
+
+func (c *Check) checkRange(anomalies []Anomaly, request *etcd.RangeRequest, response *etcd.RangeResponse, revision int64) []Anomaly {
+	v := c.session.verifier
+	historical := request.Revision > 0
+	if historical {
+		revision = request.Revision
+	}
+	for _, kv := range response.Kvs {
+		if kv.ModRevision > revision || kv.CreateRevision > kv.ModRevision {
+			anomalies = append(anomalies, Anomaly{
+				Kind:   InvalidRevision,
+				Key:    kv.Key,
+				Detail: fmt.Sprintf("created at %d, modified at %d, read at %d", kv.CreateRevision, kv.ModRevision, revision),
+			})
+			continue
+		}
+		if kv.Version < 1 || (kv.CreateRevision == kv.ModRevision && kv.Version != 1) {
+			anomalies = append(anomalies, Anomaly{
+				Kind:   InvalidVersion,
+				Key:    kv.Key,
+				Detail: fmt.Sprintf("version %d created at %d, modified at %d", kv.Version, kv.CreateRevision, kv.ModRevision),
+			})
+		}
+		if historical {
+			continue
+		}
+		state := v.key(kv.Key)
+		anomalies = c.checkWrite(anomalies, kv, state.write, request.KeysOnly)
+		anomalies = c.checkObservation(anomalies, kv, state)
+	}
+
+	// Only a plain read of a single key tells that the key is absent.
+	if historical || request.RangeEnd != "" || request.CountOnly || len(response.Kvs) != 0 ||
+		request.MinModRevision != 0 || request.MaxModRevision != 0 || request.MinCreateRevision != 0 || request.MaxCreateRevision != 0 {
+		return anomalies
+	}
+	state, ok := v.keys[request.Key]
+	if !ok || state.write.seq > c.seq || state.write.revision == 0 || state.write.deleted || state.write.mayBeDeleted || v.pendingDeletes > 0 {
+		return anomalies
+	}
+	for _, d := range v.deletes {
+		if d.covers(request.Key, state.write.revision, revision) {
+			return anomalies
+		}
+	}
+	return append(anomalies, Anomaly{
+		Kind:   LostWrite,
+		Key:    request.Key,
+		Detail: fmt.Sprintf("absent at %d, written at %d", revision, state.write.revision),
+	})
+}
+
+// checkWrite compares a key read with the latest write of it acknowledged
+// before the read began.
+func (c *Check) checkWrite(anomalies []Anomaly, kv *etcd.KeyValue, w write, keysOnly bool) []Anomaly {
+	if w.revision == 0 || w.seq > c.seq {
+		return anomalies
+	}
+	if kv.ModRevision < w.revision || (w.deleted && kv.ModRevision == w.revision) {
+		return append(anomalies, Anomaly{
+			Kind:   LostWrite,
+			Key:    kv.Key,
+			Detail: fmt.Sprintf("modified at %d, written at %d", kv.ModRevision, w.revision),
+		})
+	}
+	if kv.ModRevision == w.revision && w.valueKnown && !keysOnly && kv.Value != w.value {
+		return append(anomalies, Anomaly{
+			Kind:   ValueMismatch,
+			Key:    kv.Key,
+			Detail: fmt.Sprintf("unexpected value at %d", kv.ModRevision),
+		})
+	}
+	return anomalies
+}
+
+// checkObservation compares a key read with the latest read of it, and
+// remembers the newer of both.
+func (c *Check) checkObservation(anomalies []Anomaly, kv *etcd.KeyValue, state *keyState) []Anomaly {
+	o := state.observation
+	if o.modRevision != 0 && o.seq <= c.seq {
+		switch {
+		case kv.ModRevision < o.modRevision:
+			anomalies = append(anomalies, Anomaly{
+				Kind:   StaleRead,
+				Key:    kv.Key,
+				Detail: fmt.Sprintf("modified at %d after %d was observed", kv.ModRevision, o.modRevision),
+			})
+		case kv.CreateRevision != o.createRevision:
+		case kv.ModRevision == o.modRevision && kv.Version != o.version,
+			kv.ModRevision > o.modRevision && kv.Version <= o.version:
+			anomalies = append(anomalies, Anomaly{
+				Kind:   InvalidVersion,
+				Key:    kv.Key,
+				Detail: fmt.Sprintf("version %d at %d after version %d at %d", kv.Version, kv.ModRevision, o.version, o.modRevision),
+			})
+		}
+	}
+	if kv.ModRevision > o.modRevision {
+		state.observation = observation{
+			createRevision: kv.CreateRevision,
+			modRevision:    kv.ModRevision,
+			version:        kv.Version,
+			seq:            c.session.verifier.seq,
+		}
+	}
+	return anomalies
+}
+
+// pruneDeletes forgets the range deletes no write can be ordered before
+// anymore. A delete marks the keys known when it is acknowledged or fails and
+// those put before it and acknowledged after it, so it only matters to the
+// writes in flight, whose revisions are at least the oldest revision observed
+// when the checks in flight began.
+func (v *Verifier) pruneDeletes() {
+	oldest := v.maxRevision
+	for revision := range v.open {
+		oldest = min(oldest, revision)
+	}
+	v.deletes = slices.DeleteFunc(v.deletes, func(d rangeDelete) bool {
+		return d.revision <= oldest
+	})
+}
+
+func (v *Verifier) recordUncertainDeletes(request etcd.Request) {
+	switch r := request.(type) {
+	case *etcd.DeleteRequest:
+		rangeEnd := r.RangeEnd
+		if rangeEnd == "" {
+			rangeEnd = r.Key + "\x00"
+		}
+		d := rangeDelete{key: r.Key, rangeEnd: rangeEnd, revision: v.maxRevision, uncertain: true}
+		v.deletes = append(v.deletes, d)
+		for key, state := range v.keys {
+			if d.covers(key, state.write.revision, d.revision) {
+				state.write.mayBeDeleted = true
+			}
+		}
+	case *etcd.TxnRequest:
+		for _, op := range append(r.Success[:len(r.Success):len(r.Success)], r.Failure...) {
+			v.recordUncertainDeletes(op)
+		}
+	}
+}
+
+func hasDeletes(request etcd.Request) bool {
+	switch r := request.(type) {
+	case *etcd.DeleteRequest:
+		return true
+	case *etcd.TxnRequest:
+		for _, op := range append(r.Success[:len(r.Success):len(r.Success)], r.Failure...) {
+			if hasDeletes(op) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+package etcd_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
+)
+
+const unavailableEndpoint = "localhost:1"
+
+func TestClientFailover(t *testing.T) {
+	for _, balancer := range []string{etcd.RoundRobin, etcd.PickFirst} {
+		t.Run(balancer, func(t *testing.T) {
+			client, err := etcd.NewClient([]string{unavailableEndpoint, endpoint}, etcd.WithBalancer(balancer))
+			require.NoError(t, err)
+			defer client.Close()
+
+			for range 2 * len(client.Endpoints()) {
+				ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+				_, err := etcd.Range(ctx, client, &etcd.RangeRequest{Key: "client_key"})
+				cancel()
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestClientUnknownBalancer(t *testing.T) {
+	_, err := etcd.NewClient([]string{endpoint}, etcd.WithBalancer("unknown"))
+	assert.Error(t, err)
+}
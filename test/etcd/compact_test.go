@@ -87,6 +87,23 @@ func TestCompact(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "Physical",
+			testcases: []TestCase{
+				{
+					request:  &etcd.PutRequest{Key: "compact_key", Value: "compact_value3"},
+					response: &etcd.PutResponse{},
+				},
+				{
+					request:  &etcd.CompactRequest{Physical: true},
+					response: &etcd.CompactResponse{},
+				},
+				{
+					request: &etcd.RangeRequest{Key: "compact_key", Revision: -1},
+					err:     rpctypes.ErrGRPCCompacted,
+				},
+			},
+		},
 		{
 			name: "TearDown",
 			testcases: []TestCase{
@@ -95,7 +112,7 @@ func TestCompact(t *testing.T) {
 					response: &etcd.DeleteResponse{
 						Deleted: 1,
 						PrevKvs: []*etcd.KeyValue{
-							{Key: "compact_key", ModRevision: -1, CreateRevision: -2, Version: 2, Value: "compact_value2"},
+							{Key: "compact_key", ModRevision: -1, CreateRevision: -3, Version: 3, Value: "compact_value3"},
 						},
 					},
 				},
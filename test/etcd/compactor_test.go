@@ -0,0 +1,110 @@
+package etcd_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+
+	"github.com/ydb-platform/etcd-ydb/pkg/compactor"
+	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
+)
+
+func putRevisions(t *testing.T, kv etcd.KV, n int) int64 {
+	t.Helper()
+	var response *etcd.PutResponse
+	var err error
+	for i := range n {
+		response, err = etcd.Put(context.Background(), kv, &etcd.PutRequest{Key: "compactor_key", Value: strconv.Itoa(i)})
+		require.NoError(t, err)
+	}
+	return response.Revision
+}
+
+func TestCompactRetain(t *testing.T) {
+	kv := etcd.NewMemoryKV()
+	response, err := etcd.CompactRetain(context.Background(), kv, 10, false)
+	require.NoError(t, err)
+	assert.Nil(t, response)
+
+	revision := putRevisions(t, kv, 20)
+	_, err = etcd.CompactRetain(context.Background(), kv, 10, true)
+	require.NoError(t, err)
+
+	_, err = etcd.Range(context.Background(), kv, &etcd.RangeRequest{Key: "compactor_key", Revision: revision - 10})
+	require.NoError(t, err)
+	_, err = etcd.Range(context.Background(), kv, &etcd.RangeRequest{Key: "compactor_key", Revision: revision - 11})
+	assert.ErrorIs(t, err, rpctypes.ErrGRPCCompacted)
+}
+
+func TestCompactor(t *testing.T) {
+	t.Run("InvalidRetention", func(t *testing.T) {
+		for _, tc := range []struct {
+			mode      compactor.Mode
+			retention string
+		}{
+			{mode: compactor.Periodic, retention: "forever"},
+			{mode: compactor.Periodic, retention: "0"},
+			{mode: compactor.Revision, retention: "1h"},
+			{mode: compactor.Revision, retention: "-1"},
+			{mode: "daily", retention: "1"},
+		} {
+			_, err := compactor.New(etcd.NewMemoryKV(), tc.mode, tc.retention)
+			assert.Error(t, err, "%s %s", tc.mode, tc.retention)
+		}
+	})
+
+	t.Run("Revision", func(t *testing.T) {
+		kv := etcd.NewMemoryKV()
+		revision := putRevisions(t, kv, 20)
+		c, err := compactor.New(kv, compactor.Revision, "5", compactor.WithInterval(10*time.Millisecond))
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			c.Run(ctx)
+		}()
+		assert.Eventually(t, func() bool { return c.Stats().Revision == revision-5 }, time.Second, 10*time.Millisecond)
+
+		revision = putRevisions(t, kv, 5)
+		assert.Eventually(t, func() bool { return c.Stats().Revision == revision-5 }, time.Second, 10*time.Millisecond)
+		cancel()
+		<-done
+
+		stats := c.Stats()
+		assert.Equal(t, 2, stats.Compactions)
+		assert.Zero(t, stats.Errors)
+	})
+
+	t.Run("Periodic", func(t *testing.T) {
+		kv := etcd.NewMemoryKV()
+		c, err := compactor.New(kv, compactor.Periodic, "200ms")
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			c.Run(ctx)
+		}()
+
+		old := putRevisions(t, kv, 5)
+		time.Sleep(300 * time.Millisecond)
+		recent := putRevisions(t, kv, 5)
+		assert.Eventually(t, func() bool { return c.Stats().Revision >= old }, time.Second, 10*time.Millisecond)
+		cancel()
+		<-done
+
+		// Revisions created within the retention period stay readable.
+		_, err = etcd.Range(context.Background(), kv, &etcd.RangeRequest{Key: "compactor_key", Revision: recent})
+		assert.NoError(t, err)
+		assert.Less(t, c.Stats().Revision, recent)
+		assert.Zero(t, c.Stats().Errors)
+	})
+}
@@ -0,0 +1,255 @@
+package etcd_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ydb-platform/etcd-ydb/pkg/concurrency"
+	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
+)
+
+// expiryTimeout bounds waiting for a lease of the minimal TTL to expire.
+const expiryTimeout = 15 * time.Second
+
+func newSession(t *testing.T, opts ...concurrency.SessionOption) *concurrency.Session {
+	t.Helper()
+	session, err := concurrency.NewSession(client, opts...)
+	require.NoError(t, err)
+	return session
+}
+
+func TestMutex(t *testing.T) {
+	t.Run("SetUp", runTest(client, []TestCase{
+		{
+			request:  &etcd.RangeRequest{Key: etcd.EmptyKey, RangeEnd: etcd.EmptyKey},
+			response: &etcd.RangeResponse{Count: 0, Kvs: []*etcd.KeyValue{}},
+		},
+	}))
+
+	t.Run("Exclusive", func(t *testing.T) {
+		const workers, iterations = 4, 5
+		var wg sync.WaitGroup
+		var holders, acquired int
+		var mu sync.Mutex
+		for range workers {
+			session := newSession(t)
+			defer session.Close()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				m := concurrency.NewMutex(session, "mutex")
+				for range iterations {
+					if !assert.NoError(t, m.Lock(context.Background())) {
+						return
+					}
+					mu.Lock()
+					holders++
+					acquired++
+					assert.Equal(t, 1, holders)
+					mu.Unlock()
+					time.Sleep(time.Millisecond)
+					mu.Lock()
+					holders--
+					mu.Unlock()
+					assert.NoError(t, m.Unlock(context.Background()))
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, workers*iterations, acquired)
+	})
+
+	t.Run("TryLock", func(t *testing.T) {
+		s1, s2 := newSession(t), newSession(t)
+		defer s1.Close()
+		defer s2.Close()
+		m1, m2 := concurrency.NewMutex(s1, "mutex"), concurrency.NewMutex(s2, "mutex")
+
+		require.NoError(t, m1.TryLock(context.Background()))
+		assert.ErrorIs(t, m2.TryLock(context.Background()), concurrency.ErrLocked)
+
+		response, err := etcd.Txn(context.Background(), client, &etcd.TxnRequest{
+			Compare: []etcd.Compare{m1.IsOwner()},
+		})
+		require.NoError(t, err)
+		assert.True(t, response.Succeeded)
+
+		require.NoError(t, m1.Unlock(context.Background()))
+		require.NoError(t, m2.TryLock(context.Background()))
+		require.NoError(t, m2.Unlock(context.Background()))
+	})
+
+	t.Run("WaitPredecessor", func(t *testing.T) {
+		s1, s2 := newSession(t), newSession(t)
+		defer s1.Close()
+		defer s2.Close()
+		m1, m2 := concurrency.NewMutex(s1, "mutex"), concurrency.NewMutex(s2, "mutex")
+		require.NoError(t, m1.Lock(context.Background()))
+
+		locked := make(chan error)
+		go func() { locked <- m2.Lock(context.Background()) }()
+		select {
+		case err := <-locked:
+			t.Fatalf("locked while held by another session: %v", err)
+		case <-time.After(500 * time.Millisecond):
+		}
+
+		require.NoError(t, m1.Unlock(context.Background()))
+		require.NoError(t, <-locked)
+		require.NoError(t, m2.Unlock(context.Background()))
+	})
+
+	t.Run("CanceledLock", func(t *testing.T) {
+		s1, s2 := newSession(t), newSession(t)
+		defer s1.Close()
+		defer s2.Close()
+		m1, m2 := concurrency.NewMutex(s1, "mutex"), concurrency.NewMutex(s2, "mutex")
+		require.NoError(t, m1.Lock(context.Background()))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		assert.Error(t, m2.Lock(ctx))
+
+		// The canceled waiter must not stay in the queue.
+		response, err := etcd.Range(context.Background(), client, &etcd.RangeRequest{Key: m2.Key()})
+		require.NoError(t, err)
+		assert.Empty(t, response.Kvs)
+		require.NoError(t, m1.Unlock(context.Background()))
+	})
+
+	t.Run("LeaseExpiry", func(t *testing.T) {
+		s1, s2 := newSession(t, concurrency.WithTTL(1)), newSession(t)
+		defer s2.Close()
+		m1, m2 := concurrency.NewMutex(s1, "mutex"), concurrency.NewMutex(s2, "mutex")
+		require.NoError(t, m1.Lock(context.Background()))
+		s1.Orphan()
+
+		ctx, cancel := context.WithTimeout(context.Background(), expiryTimeout)
+		defer cancel()
+		require.NoError(t, m2.Lock(ctx))
+
+		response, err := etcd.Range(context.Background(), client, &etcd.RangeRequest{Key: m1.Key()})
+		require.NoError(t, err)
+		assert.Empty(t, response.Kvs)
+		require.NoError(t, m2.Unlock(context.Background()))
+	})
+
+	syncRevision(t, client)
+	t.Run("TearDown", runTest(client, []TestCase{
+		{
+			request:  &etcd.RangeRequest{Key: etcd.EmptyKey, RangeEnd: etcd.EmptyKey},
+			response: &etcd.RangeResponse{Count: 0, Kvs: []*etcd.KeyValue{}},
+		},
+	}))
+}
+
+func TestElection(t *testing.T) {
+	t.Run("SetUp", runTest(client, []TestCase{
+		{
+			request:  &etcd.RangeRequest{Key: etcd.EmptyKey, RangeEnd: etcd.EmptyKey},
+			response: &etcd.RangeResponse{Count: 0, Kvs: []*etcd.KeyValue{}},
+		},
+	}))
+
+	t.Run("NoLeader", func(t *testing.T) {
+		session := newSession(t)
+		defer session.Close()
+		e := concurrency.NewElection(session, "election")
+
+		_, err := e.Leader(context.Background())
+		assert.ErrorIs(t, err, concurrency.ErrElectionNoLeader)
+		assert.ErrorIs(t, e.Proclaim(context.Background(), "value"), concurrency.ErrElectionNotLeader)
+		assert.NoError(t, e.Resign(context.Background()))
+	})
+
+	t.Run("Succession", func(t *testing.T) {
+		s1, s2, s3 := newSession(t), newSession(t, concurrency.WithTTL(1)), newSession(t)
+		defer s1.Close()
+		defer s3.Close()
+		e1 := concurrency.NewElection(s1, "election")
+		e2 := concurrency.NewElection(s2, "election")
+		e3 := concurrency.NewElection(s3, "election")
+
+		ctx, cancel := context.WithTimeout(context.Background(), expiryTimeout)
+		defer cancel()
+		observed := concurrency.NewElection(s3, "election").Observe(ctx)
+		expectLeader := func(value string) {
+			t.Helper()
+			select {
+			case kv, ok := <-observed:
+				if assert.True(t, ok) {
+					assert.Equal(t, value, kv.Value)
+				}
+			case <-ctx.Done():
+				t.Fatalf("leader %q was not observed", value)
+			}
+		}
+
+		require.NoError(t, e1.Campaign(context.Background(), "e1"))
+		expectLeader("e1")
+		leader, err := e1.Leader(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, e1.Key(), leader.Key)
+		assert.Equal(t, e1.Revision(), leader.CreateRevision)
+
+		elected := make(chan error, 2)
+		go func() { elected <- e2.Campaign(context.Background(), "e2") }()
+		select {
+		case err := <-elected:
+			t.Fatalf("elected while another candidate leads: %v", err)
+		case <-time.After(500 * time.Millisecond):
+		}
+
+		require.NoError(t, e1.Proclaim(context.Background(), "e1-proclaimed"))
+		expectLeader("e1-proclaimed")
+
+		require.NoError(t, e1.Resign(context.Background()))
+		require.NoError(t, <-elected)
+		expectLeader("e2")
+
+		go func() { elected <- e3.Campaign(context.Background(), "e3") }()
+		s2.Orphan()
+		require.NoError(t, <-elected)
+		expectLeader("e3")
+
+		assert.ErrorIs(t, e2.Proclaim(context.Background(), "e2-stale"), concurrency.ErrElectionNotLeader)
+		require.NoError(t, e3.Resign(context.Background()))
+	})
+
+	t.Run("Resume", func(t *testing.T) {
+		s1, s2 := newSession(t), newSession(t)
+		defer s1.Close()
+		defer s2.Close()
+		sibling := concurrency.NewElection(s2, "electionfoo")
+		require.NoError(t, sibling.Campaign(context.Background(), "sibling"))
+		e := concurrency.NewElection(s1, "election")
+		require.NoError(t, e.Campaign(context.Background(), "e"))
+
+		resumed := concurrency.ResumeElection(s1, "election", e.Key(), e.Revision())
+		leader, err := resumed.Leader(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, e.Key(), leader.Key)
+		require.NoError(t, resumed.Proclaim(context.Background(), "resumed"))
+		leader, err = e.Leader(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "resumed", leader.Value)
+
+		require.NoError(t, resumed.Resign(context.Background()))
+		_, err = e.Leader(context.Background())
+		assert.ErrorIs(t, err, concurrency.ErrElectionNoLeader)
+		require.NoError(t, sibling.Resign(context.Background()))
+	})
+
+	syncRevision(t, client)
+	t.Run("TearDown", runTest(client, []TestCase{
+		{
+			request:  &etcd.RangeRequest{Key: etcd.EmptyKey, RangeEnd: etcd.EmptyKey},
+			response: &etcd.RangeResponse{Count: 0, Kvs: []*etcd.KeyValue{}},
+		},
+	}))
+}
@@ -0,0 +1,94 @@
+package etcd_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
+)
+
+func encodeTestRequests(t *testing.T) []etcd.Request {
+	t.Helper()
+	txn, err := etcd.NewTxn().
+		If(
+			etcd.Value("encode_a").Equal("a"),
+			etcd.Version("encode_").WithPrefix().Greater(0),
+			etcd.ModRevision("encode_b").Less(100),
+			etcd.CreateRevision("encode_b").NotEqual(0),
+			etcd.LeaseID("encode_c").Equal(0),
+		).
+		Then(
+			&etcd.PutRequest{Key: "encode_c", Value: "c", PrevKv: true},
+			&etcd.DeleteRequest{Key: "encode_a", RangeEnd: "encode_b", PrevKv: true},
+		).
+		ThenTxn(etcd.NewTxn().Then(&etcd.RangeRequest{Key: "encode_", RangeEnd: etcd.GetPrefix("encode_")})).
+		Else(etcd.RangeRequest{Key: "encode_", RangeEnd: etcd.GetPrefix("encode_"), Limit: 2}.OrderByModRevision().Descending()).
+		Build()
+	require.NoError(t, err)
+	return []etcd.Request{
+		&etcd.PutRequest{Key: "encode_a", Value: "a"},
+		&etcd.PutRequest{Key: "encode_b", Value: "b", PrevKv: true},
+		&etcd.RangeRequest{Key: "encode_", RangeEnd: etcd.GetPrefix("encode_"), KeysOnly: true, MinModRevision: 2},
+		txn,
+		&etcd.DeleteRequest{Key: "encode_b", PrevKv: true},
+		&etcd.CompactRequest{Revision: 2, Physical: true},
+	}
+}
+
+func TestEncode(t *testing.T) {
+	kv := etcd.NewMemoryKV()
+	for _, request := range encodeTestRequests(t) {
+		method, err := etcd.RequestMethod(request)
+		require.NoError(t, err)
+		response, err := etcd.Do(context.Background(), kv, request)
+		require.NoError(t, err)
+
+		t.Run(method+"/JSON", func(t *testing.T) {
+			data, err := etcd.MarshalRequest(request)
+			require.NoError(t, err)
+			decodedRequest, err := etcd.UnmarshalRequest(data)
+			require.NoError(t, err)
+			assert.Equal(t, request, decodedRequest)
+
+			data, err = etcd.MarshalResponse(response)
+			require.NoError(t, err)
+			decodedResponse, err := etcd.UnmarshalResponse(data)
+			require.NoError(t, err)
+			assert.Equal(t, response, decodedResponse)
+		})
+
+		t.Run(method+"/Proto", func(t *testing.T) {
+			data, err := etcd.MarshalRequestProto(request)
+			require.NoError(t, err)
+			decodedRequest, err := etcd.UnmarshalRequestProto(method, data)
+			require.NoError(t, err)
+			assert.Equal(t, request, decodedRequest)
+
+			data, err = etcd.MarshalResponseProto(response)
+			require.NoError(t, err)
+			decodedResponse, err := etcd.UnmarshalResponseProto(method, data)
+			require.NoError(t, err)
+			assert.Equal(t, response, decodedResponse)
+		})
+	}
+
+	t.Run("Tagged", func(t *testing.T) {
+		data, err := etcd.MarshalRequest(&etcd.PutRequest{Key: "encode_a", Value: "a"})
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"method":"Put","value":{"key":"encode_a","value":"a"}}`, string(data))
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		_, err := etcd.MarshalRequest(unknownRequest{})
+		assert.ErrorIs(t, err, etcd.ErrUnknownRequest)
+		_, err = etcd.UnmarshalRequest([]byte(`{"method":"Watch","value":{}}`))
+		assert.ErrorIs(t, err, etcd.ErrUnknownRequest)
+		_, err = etcd.UnmarshalResponse([]byte(`{"method":"Txn","value":{"responses":[{"method":"Lease"}]}}`))
+		assert.ErrorIs(t, err, etcd.ErrUnknownResponse)
+		_, err = etcd.MarshalRequestProto((*etcd.PutRequest)(nil))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,211 @@
+package etcd_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
+	"github.com/ydb-platform/etcd-ydb/pkg/proxy"
+)
+
+var faultRetryPolicy = etcd.RetryPolicy{
+	MaxRetries:  20,
+	BaseBackoff: 10 * time.Millisecond,
+	MaxBackoff:  500 * time.Millisecond,
+	Codes:       []codes.Code{codes.Unavailable},
+}
+
+func TestFaults(t *testing.T) {
+	t.Run("SetUp", runTest(client, []TestCase{
+		{
+			request:  &etcd.RangeRequest{Key: etcd.EmptyKey, RangeEnd: etcd.EmptyKey},
+			response: &etcd.RangeResponse{Count: 0, Kvs: []*etcd.KeyValue{}},
+		},
+	}))
+
+	p, addr := startProxy(t)
+	proxied := newProxiedClient(t, addr)
+	retrying := newProxiedClient(t, addr, etcd.WithRetryPolicy(faultRetryPolicy))
+	bounded := newProxiedClient(t, addr, etcd.WithCallTimeout(200*time.Millisecond))
+
+	t.Run("Unavailable", func(t *testing.T) {
+		require.NoError(t, p.Inject(proxy.Fault{Kind: proxy.FaultUnavailable}))
+		defer p.Clear()
+
+		_, err := etcd.Range(context.Background(), proxied, &etcd.RangeRequest{Key: "fault_key"})
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+
+		var retries int
+		ctx := etcd.WithRetries(context.Background(), &retries)
+		_, err = etcd.Put(ctx, retrying, &etcd.PutRequest{Key: "fault_key", Value: "fault_value"})
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+		assert.Equal(t, 0, retries)
+
+		time.AfterFunc(200*time.Millisecond, func() { p.Clear(proxy.FaultUnavailable) })
+		_, err = etcd.Range(ctx, retrying, &etcd.RangeRequest{Key: "fault_key"})
+		assert.NoError(t, err)
+		assert.Positive(t, retries)
+	})
+
+	t.Run("Rate", func(t *testing.T) {
+		require.NoError(t, p.Inject(proxy.Fault{
+			Kind:    proxy.FaultUnavailable,
+			Rate:    0.5,
+			Methods: []string{"/etcdserverpb.KV/Range"},
+		}))
+		defer p.Clear()
+
+		total := 0
+		for range 20 {
+			var retries int
+			ctx := etcd.WithRetries(context.Background(), &retries)
+			_, err := etcd.Range(ctx, retrying, &etcd.RangeRequest{Key: "fault_key"})
+			require.NoError(t, err)
+			total += retries
+		}
+		assert.Positive(t, total)
+
+		_, err := etcd.Delete(context.Background(), proxied, &etcd.DeleteRequest{Key: "fault_key"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Latency", func(t *testing.T) {
+		require.NoError(t, p.Inject(proxy.Fault{Kind: proxy.FaultLatency, Latency: 200 * time.Millisecond}))
+		defer p.Clear()
+
+		start := time.Now()
+		_, err := etcd.Range(context.Background(), proxied, &etcd.RangeRequest{Key: "fault_key"})
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+
+		_, err = etcd.Range(context.Background(), bounded, &etcd.RangeRequest{Key: "fault_key"})
+		assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	})
+
+	t.Run("Blackhole", func(t *testing.T) {
+		require.NoError(t, p.Inject(proxy.Fault{Kind: proxy.FaultBlackhole}))
+		_, err := etcd.Range(context.Background(), bounded, &etcd.RangeRequest{Key: "fault_key"})
+		assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+
+		p.Clear()
+		_, err = etcd.Range(context.Background(), bounded, &etcd.RangeRequest{Key: "fault_key"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Pause", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		events, err := etcd.Watch(ctx, proxied, &etcd.WatchRequest{Key: "fault_key"})
+		require.NoError(t, err)
+
+		require.NoError(t, p.Inject(proxy.Fault{Kind: proxy.FaultPause}))
+		_, err = etcd.Put(context.Background(), client, &etcd.PutRequest{Key: "fault_key", Value: "fault_paused"})
+		require.NoError(t, err)
+		select {
+		case <-events:
+			t.Fatal("watch event was forwarded while paused")
+		case <-time.After(300 * time.Millisecond):
+		}
+
+		p.Clear(proxy.FaultPause)
+		select {
+		case response, ok := <-events:
+			require.True(t, ok)
+			require.Len(t, response.Events, 1)
+			assert.Equal(t, "fault_paused", response.Events[0].Kv.Value)
+		case <-ctx.Done():
+			t.Fatal("watch event was not forwarded after the pause")
+		}
+		_, err = etcd.Delete(context.Background(), client, &etcd.DeleteRequest{Key: "fault_key"})
+		require.NoError(t, err)
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		events, err := etcd.Watch(ctx, proxied, &etcd.WatchRequest{Key: "fault_key"})
+		require.NoError(t, err)
+
+		require.NoError(t, p.Inject(proxy.Fault{Kind: proxy.FaultReset}))
+		select {
+		case response, ok := <-events:
+			require.True(t, ok)
+			assert.True(t, response.Canceled)
+			assert.Error(t, response.Err)
+			_, ok = <-events
+			assert.False(t, ok)
+		case <-ctx.Done():
+			t.Fatal("watch survived the reset")
+		}
+		assert.Empty(t, p.Faults())
+
+		_, err = etcd.Range(ctx, retrying, &etcd.RangeRequest{Key: "fault_key"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Drop", func(t *testing.T) {
+		require.NoError(t, p.Inject(proxy.Fault{Kind: proxy.FaultDrop}))
+		p.Reset()
+		_, err := etcd.Range(context.Background(), bounded, &etcd.RangeRequest{Key: "fault_key"})
+		assert.Error(t, err)
+
+		p.Clear(proxy.FaultDrop)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_, err = etcd.Range(ctx, retrying, &etcd.RangeRequest{Key: "fault_key"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Schedule", func(t *testing.T) {
+		steps, err := proxy.ParseSchedule("unavailable@0s+300ms")
+		require.NoError(t, err)
+		done := make(chan error, 1)
+		go func() { done <- p.RunSchedule(context.Background(), steps) }()
+
+		require.Eventually(t, func() bool { return len(p.Faults()) == 1 }, time.Second, 10*time.Millisecond)
+		_, err = etcd.Range(context.Background(), proxied, &etcd.RangeRequest{Key: "fault_key"})
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+
+		require.NoError(t, <-done)
+		assert.Empty(t, p.Faults())
+		_, err = etcd.Range(context.Background(), proxied, &etcd.RangeRequest{Key: "fault_key"})
+		assert.NoError(t, err)
+	})
+
+	syncRevision(t, client)
+	t.Run("TearDown", runTest(client, []TestCase{
+		{
+			request:  &etcd.RangeRequest{Key: etcd.EmptyKey, RangeEnd: etcd.EmptyKey},
+			response: &etcd.RangeResponse{Count: 0, Kvs: []*etcd.KeyValue{}},
+		},
+	}))
+}
+
+func TestParseSchedule(t *testing.T) {
+	steps, err := proxy.ParseSchedule("latency:50ms@10s+20s, unavailable*0.5@1m+10s,reset@2m")
+	require.NoError(t, err)
+	assert.Equal(t, []proxy.Step{
+		{At: 10 * time.Second, Duration: 20 * time.Second, Fault: proxy.Fault{Kind: proxy.FaultLatency, Latency: 50 * time.Millisecond}},
+		{At: time.Minute, Duration: 10 * time.Second, Fault: proxy.Fault{Kind: proxy.FaultUnavailable, Rate: 0.5}},
+		{At: 2 * time.Minute, Fault: proxy.Fault{Kind: proxy.FaultReset}},
+	}, steps)
+
+	for _, schedule := range []string{
+		"unavailable",
+		"unknown@1s",
+		"latency@1s",
+		"pause:1s@1s",
+		"unavailable*2@1s",
+		"unavailable@-1s",
+		"unavailable@1s+x",
+	} {
+		_, err := proxy.ParseSchedule(schedule)
+		assert.Error(t, err, schedule)
+	}
+}
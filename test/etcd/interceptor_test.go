@@ -0,0 +1,49 @@
+package etcd_test
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
+)
+
+func TestInterceptors(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	metrics := etcd.NewMetrics()
+	client, err := etcd.NewClient(
+		[]string{endpoint},
+		etcd.WithUnaryInterceptors(etcd.LoggingUnaryInterceptor(logger), metrics.UnaryClientInterceptor()),
+	)
+	require.NoError(t, err)
+	defer client.Close()
+
+	runTest(client, []TestCase{
+		{
+			request:  &etcd.RangeRequest{Key: etcd.EmptyKey, RangeEnd: etcd.EmptyKey},
+			response: &etcd.RangeResponse{Count: 0, Kvs: []*etcd.KeyValue{}},
+		},
+		{
+			request:  &etcd.PutRequest{Key: "interceptor_key", Value: "interceptor_secret"},
+			response: &etcd.PutResponse{},
+		},
+		{
+			request:  &etcd.DeleteRequest{Key: "interceptor_key"},
+			response: &etcd.DeleteResponse{Deleted: 1, PrevKvs: []*etcd.KeyValue{}},
+		},
+	})(t)
+
+	assert.Contains(t, logs.String(), `"key":"interceptor_key"`)
+	assert.NotContains(t, logs.String(), "interceptor_secret")
+
+	var exposition strings.Builder
+	require.NoError(t, metrics.WritePrometheus(&exposition))
+	for _, method := range []string{"Range", "Put", "DeleteRange"} {
+		assert.Contains(t, exposition.String(), `grpc_client_handled_total{grpc_type="unary",grpc_service="etcdserverpb.KV",grpc_method="`+method+`",grpc_code="OK"} 1`)
+	}
+}
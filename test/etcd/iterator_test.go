@@ -0,0 +1,101 @@
+package etcd_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+
+	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
+)
+
+func collectKeys(t *testing.T, it *etcd.RangeIterator) []string {
+	t.Helper()
+	var keys []string
+	for it.Next(context.Background()) {
+		keys = append(keys, it.KeyValue().Key)
+	}
+	assert.NoError(t, it.Err())
+	return keys
+}
+
+func TestRangeIterator(t *testing.T) {
+	setUp := []TestCase{
+		{
+			request:  &etcd.RangeRequest{Key: etcd.EmptyKey, RangeEnd: etcd.EmptyKey},
+			response: &etcd.RangeResponse{Count: 0, Kvs: []*etcd.KeyValue{}},
+		},
+	}
+	for _, key := range []string{"iterator_key1", "iterator_key2", "iterator_key3", "iterator_key4", "iterator_key5"} {
+		setUp = append(setUp, TestCase{
+			request:  &etcd.PutRequest{Key: key, Value: "iterator_value"},
+			response: &etcd.PutResponse{},
+		})
+	}
+	t.Run("SetUp", runTest(client, setUp))
+
+	prefix := &etcd.RangeRequest{Key: "iterator_", RangeEnd: etcd.GetPrefix("iterator_")}
+
+	t.Run("Ascending", func(t *testing.T) {
+		for _, pageSize := range []int64{1, 2, 5, 10} {
+			keys := collectKeys(t, etcd.NewRangeIterator(client, prefix, pageSize))
+			assert.Equal(t, []string{"iterator_key1", "iterator_key2", "iterator_key3", "iterator_key4", "iterator_key5"}, keys)
+		}
+	})
+
+	t.Run("Descending", func(t *testing.T) {
+		keys := collectKeys(t, etcd.NewRangeIterator(client, prefix.OrderByKey().Descending(), 2))
+		assert.Equal(t, []string{"iterator_key5", "iterator_key4", "iterator_key3", "iterator_key2", "iterator_key1"}, keys)
+	})
+
+	t.Run("KeysOnly", func(t *testing.T) {
+		it := etcd.NewRangeIterator(client, &etcd.RangeRequest{Key: "iterator_", RangeEnd: etcd.GetPrefix("iterator_"), KeysOnly: true}, 2)
+		for it.Next(context.Background()) {
+			assert.Empty(t, it.KeyValue().Value)
+		}
+		assert.NoError(t, it.Err())
+	})
+
+	t.Run("PinnedRevision", func(t *testing.T) {
+		it := etcd.NewRangeIterator(client, prefix, 2)
+		assert.True(t, it.Next(context.Background()))
+		runTestCase(t, client, TestCase{
+			request:  &etcd.PutRequest{Key: "iterator_key6", Value: "iterator_value"},
+			response: &etcd.PutResponse{},
+		})
+		keys := []string{it.KeyValue().Key}
+		keys = append(keys, collectKeys(t, it)...)
+		assert.Equal(t, []string{"iterator_key1", "iterator_key2", "iterator_key3", "iterator_key4", "iterator_key5"}, keys)
+	})
+
+	t.Run("Compacted", func(t *testing.T) {
+		it := etcd.NewRangeIterator(client, prefix, 2)
+		assert.True(t, it.Next(context.Background()))
+		runTestCase(t, client, TestCase{
+			request:  &etcd.DeleteRequest{Key: "iterator_key6"},
+			response: &etcd.DeleteResponse{Deleted: 1, PrevKvs: []*etcd.KeyValue{}},
+		})
+		runTestCase(t, client, TestCase{
+			request:  &etcd.CompactRequest{},
+			response: &etcd.CompactResponse{},
+		})
+		for it.Next(context.Background()) {
+		}
+		var compacted *etcd.CompactedError
+		assert.ErrorAs(t, it.Err(), &compacted)
+		assert.ErrorIs(t, it.Err(), rpctypes.ErrGRPCCompacted)
+		assert.Equal(t, it.Revision(), compacted.Revision)
+	})
+
+	t.Run("TearDown", runTest(client, []TestCase{
+		{
+			request:  &etcd.DeleteRequest{Key: "iterator_", RangeEnd: etcd.GetPrefix("iterator_")},
+			response: &etcd.DeleteResponse{Deleted: 5, PrevKvs: []*etcd.KeyValue{}},
+		},
+		{
+			request:  &etcd.RangeRequest{Key: etcd.EmptyKey, RangeEnd: etcd.EmptyKey},
+			response: &etcd.RangeResponse{Count: 0, Kvs: []*etcd.KeyValue{}},
+		},
+	}))
+}
@@ -0,0 +1,107 @@
+package etcd_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+
+	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
+)
+
+func kvTestCases() []TestCase {
+	return []TestCase{
+		{
+			request:  &etcd.RangeRequest{Key: etcd.EmptyKey, RangeEnd: etcd.EmptyKey},
+			response: &etcd.RangeResponse{Count: 0, Kvs: []*etcd.KeyValue{}},
+		},
+		{
+			request:  &etcd.PutRequest{Key: "kv_key", Value: "kv_value1"},
+			response: &etcd.PutResponse{},
+		},
+		{
+			request: &etcd.TxnRequest{
+				Compare: []etcd.Compare{etcd.Compare{Key: "kv_key"}.Equal().SetVersion(1)},
+				Success: []etcd.Request{&etcd.PutRequest{Key: "kv_key", Value: "kv_value2", PrevKv: true}},
+			},
+			response: &etcd.TxnResponse{
+				Succeeded: true,
+				Responses: []etcd.Response{
+					&etcd.PutResponse{PrevKv: &etcd.KeyValue{Key: "kv_key", ModRevision: -1, CreateRevision: -1, Version: 1, Value: "kv_value1"}},
+				},
+			},
+		},
+		{
+			request:  &etcd.CompactRequest{},
+			response: &etcd.CompactResponse{},
+		},
+		{
+			request: &etcd.RangeRequest{Key: "kv_key", Revision: -1},
+			err:     rpctypes.ErrGRPCCompacted,
+		},
+		{
+			request:  &etcd.DeleteRequest{Key: "kv_", RangeEnd: etcd.GetPrefix("kv_"), PrevKv: true},
+			response: &etcd.DeleteResponse{Deleted: 1, PrevKvs: []*etcd.KeyValue{{Key: "kv_key", ModRevision: -1, CreateRevision: -2, Version: 2, Value: "kv_value2"}}},
+		},
+		{
+			request:  &etcd.RangeRequest{Key: etcd.EmptyKey, RangeEnd: etcd.EmptyKey},
+			response: &etcd.RangeResponse{Count: 0, Kvs: []*etcd.KeyValue{}},
+		},
+	}
+}
+
+func TestMemoryKV(t *testing.T) {
+	runIsolatedTest(etcd.NewMemoryKV(), kvTestCases())(t)
+}
+
+func TestRecordReplay(t *testing.T) {
+	recorder := etcd.NewRecorder(etcd.NewMemoryKV())
+	t.Run("Record", runIsolatedTest(recorder, kvTestCases()))
+
+	replayer := etcd.NewReplayer(recorder.Records())
+	t.Run("Replay", runIsolatedTest(replayer, kvTestCases()))
+	assert.Equal(t, 0, replayer.Remaining())
+
+	_, err := etcd.Put(context.Background(), replayer, &etcd.PutRequest{Key: "kv_key", Value: "kv_value3"})
+	assert.Error(t, err)
+}
+
+func TestRecordEncoding(t *testing.T) {
+	recorder := etcd.NewRecorder(etcd.NewMemoryKV())
+	t.Run("Record", runIsolatedTest(recorder, kvTestCases()))
+
+	var records []etcd.Record
+	for _, rec := range recorder.Records() {
+		data, err := json.Marshal(rec)
+		require.NoError(t, err)
+		var decoded etcd.Record
+		require.NoError(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, rec.Method, decoded.Method)
+		assert.True(t, rec.Start.Equal(decoded.Start))
+		assert.Equal(t, rec.Duration, decoded.Duration)
+		assert.Equal(t, rec.Err == nil, decoded.Err == nil)
+		records = append(records, decoded)
+	}
+
+	replayer := etcd.NewReplayer(records)
+	t.Run("Replay", runIsolatedTest(replayer, kvTestCases()))
+	assert.Equal(t, 0, replayer.Remaining())
+
+	t.Run("Invoke", func(t *testing.T) {
+		kv := etcd.NewMemoryKV()
+		for _, rec := range records {
+			_, err := etcd.Invoke(context.Background(), kv, rec.Method, rec.Request)
+			assert.Equal(t, rec.Err == nil, err == nil, rec.Method)
+		}
+		_, err := etcd.Invoke(context.Background(), kv, etcd.MethodPut, records[0].Request)
+		assert.ErrorIs(t, err, etcd.ErrUnknownRequest)
+	})
+
+	t.Run("UnknownMethod", func(t *testing.T) {
+		var rec etcd.Record
+		assert.Error(t, json.Unmarshal([]byte(`{"method":"Watch","request":""}`), &rec))
+	})
+}
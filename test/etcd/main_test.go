@@ -15,7 +15,7 @@ var client *etcd.Client
 
 func Init() {
 	var err error
-	client, err = etcd.NewClient(endpoint)
+	client, err = etcd.NewClient([]string{endpoint})
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,45 @@
+package etcd_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
+)
+
+func TestClientOptions(t *testing.T) {
+	t.Run("MaxCallSendMsgSize", func(t *testing.T) {
+		client, err := etcd.NewClient([]string{endpoint}, etcd.WithMaxCallSendMsgSize(64))
+		require.NoError(t, err)
+		defer client.Close()
+
+		_, err = etcd.Put(context.Background(), client, &etcd.PutRequest{Key: "options_key", Value: strings.Repeat("-", 128)})
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+	})
+
+	t.Run("CallTimeout", func(t *testing.T) {
+		client, err := etcd.NewClient([]string{unavailableEndpoint}, etcd.WithCallTimeout(100*time.Millisecond))
+		require.NoError(t, err)
+		defer client.Close()
+
+		_, err = etcd.Range(context.Background(), client, &etcd.RangeRequest{Key: "options_key"})
+		assert.Equal(t, codes.DeadlineExceeded, status.Code(err))
+	})
+
+	t.Run("Compression", func(t *testing.T) {
+		client, err := etcd.NewClient([]string{endpoint}, etcd.WithCompression("gzip"), etcd.WithUserAgent("etcd-ydb-test"))
+		require.NoError(t, err)
+		defer client.Close()
+
+		response, err := etcd.Range(context.Background(), client, &etcd.RangeRequest{Key: "options_key"})
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), response.Count)
+	})
+}
@@ -0,0 +1,131 @@
+package etcd_test
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+
+	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
+	"github.com/ydb-platform/etcd-ydb/pkg/proxy"
+)
+
+// startProxy serves a proxy in front of the shared client and returns its
+// address.
+func startProxy(t *testing.T, opts ...proxy.Option) (*proxy.Proxy, string) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	p := proxy.New(client.Conn(), opts...)
+	go p.Serve(lis)
+	t.Cleanup(p.Stop)
+	return p, lis.Addr().String()
+}
+
+func newProxiedClient(t *testing.T, addr string, opts ...etcd.Option) *etcd.Client {
+	t.Helper()
+	proxied, err := etcd.NewClient([]string{addr}, opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { proxied.Close() })
+	return proxied
+}
+
+func TestProxy(t *testing.T) {
+	var mu sync.Mutex
+	var calls []proxy.Call
+	_, addr := startProxy(t, proxy.WithObserver(func(call proxy.Call) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, call)
+	}))
+	proxied := newProxiedClient(t, addr)
+
+	t.Run("KV", runTest(proxied, []TestCase{
+		{
+			request:  &etcd.RangeRequest{Key: etcd.EmptyKey, RangeEnd: etcd.EmptyKey},
+			response: &etcd.RangeResponse{Count: 0, Kvs: []*etcd.KeyValue{}},
+		},
+		{
+			request:  &etcd.PutRequest{Key: "proxy_key", Value: "proxy_value"},
+			response: &etcd.PutResponse{},
+		},
+		{
+			request: &etcd.PutRequest{Key: "proxy_key", Value: "proxy_value", Lease: 1, IgnoreLease: true},
+			err:     rpctypes.ErrGRPCLeaseProvided,
+		},
+		{
+			request:  &etcd.DeleteRequest{Key: "proxy_key"},
+			response: &etcd.DeleteResponse{Deleted: 1, PrevKvs: []*etcd.KeyValue{}},
+		},
+	}))
+
+	t.Run("Calls", func(t *testing.T) {
+		mu.Lock()
+		defer mu.Unlock()
+		var methods []string
+		for _, call := range calls {
+			method, ok := etcd.KVMethod(call.Method)
+			require.True(t, ok, call.Method)
+			assert.True(t, call.Unary())
+			methods = append(methods, method)
+
+			rec, err := etcd.UnmarshalRecord(method, call.Request, call.Response)
+			require.NoError(t, err)
+			if call.Err != nil {
+				assert.Nil(t, rec.Response)
+				continue
+			}
+			assert.NotNil(t, rec.Response)
+		}
+		assert.Equal(t, []string{etcd.MethodRange, etcd.MethodPut, etcd.MethodPut, etcd.MethodDelete}, methods)
+		if assert.Len(t, calls, 4) {
+			rec, err := etcd.UnmarshalRecord(etcd.MethodPut, calls[1].Request, calls[1].Response)
+			require.NoError(t, err)
+			assert.Equal(t, "proxy_key", string(rec.Request.(*etcdserverpb.PutRequest).Key))
+			assert.ErrorIs(t, calls[2].Err, rpctypes.ErrGRPCLeaseProvided)
+		}
+	})
+
+	t.Run("Watch", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		events, err := etcd.Watch(ctx, proxied, &etcd.WatchRequest{Key: "proxy_key"})
+		require.NoError(t, err)
+		_, err = etcd.Put(context.Background(), client, &etcd.PutRequest{Key: "proxy_key", Value: "proxy_watched"})
+		require.NoError(t, err)
+		select {
+		case response, ok := <-events:
+			require.True(t, ok)
+			require.Len(t, response.Events, 1)
+			assert.Equal(t, "proxy_watched", response.Events[0].Kv.Value)
+		case <-ctx.Done():
+			t.Fatal("watch event was not forwarded")
+		}
+		_, err = etcd.Delete(context.Background(), client, &etcd.DeleteRequest{Key: "proxy_key"})
+		require.NoError(t, err)
+	})
+
+	t.Run("Lease", func(t *testing.T) {
+		grant, err := etcd.LeaseGrant(context.Background(), proxied, &etcd.LeaseGrantRequest{TTL: 60})
+		require.NoError(t, err)
+		ttl, err := etcd.LeaseTimeToLive(context.Background(), proxied, &etcd.LeaseTimeToLiveRequest{ID: grant.ID})
+		require.NoError(t, err)
+		assert.Equal(t, int64(60), ttl.GrantedTTL)
+		_, err = etcd.LeaseRevoke(context.Background(), proxied, &etcd.LeaseRevokeRequest{ID: grant.ID})
+		require.NoError(t, err)
+	})
+
+	syncRevision(t, client)
+	t.Run("TearDown", runTest(client, []TestCase{
+		{
+			request:  &etcd.RangeRequest{Key: etcd.EmptyKey, RangeEnd: etcd.EmptyKey},
+			response: &etcd.RangeResponse{Count: 0, Kvs: []*etcd.KeyValue{}},
+		},
+	}))
+}
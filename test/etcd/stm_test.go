@@ -0,0 +1,98 @@
+package etcd_test
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
+)
+
+func TestSTM(t *testing.T) {
+	t.Run("SetUp", runTest(client, []TestCase{
+		{
+			request:  &etcd.RangeRequest{Key: etcd.EmptyKey, RangeEnd: etcd.EmptyKey},
+			response: &etcd.RangeResponse{Count: 0, Kvs: []*etcd.KeyValue{}},
+		},
+	}))
+
+	for _, tc := range []struct {
+		name      string
+		isolation etcd.Isolation
+	}{
+		{name: "SerializableSnapshot", isolation: etcd.SerializableSnapshot},
+		{name: "Serializable", isolation: etcd.Serializable},
+		{name: "RepeatableReads", isolation: etcd.RepeatableReads},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			const workers, increments = 4, 10
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			totalConflicts := 0
+			for range workers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for range increments {
+						var conflicts int
+						_, err := etcd.NewSTM(context.Background(), client, func(stm etcd.STM) error {
+							value, err := stm.Get("stm_counter")
+							if err != nil {
+								return err
+							}
+							counter, _ := strconv.Atoi(value)
+							stm.Put("stm_counter", strconv.Itoa(counter+1))
+							stm.Put("stm_last", tc.name)
+							return nil
+						}, etcd.WithIsolation(tc.isolation), etcd.WithConflicts(&conflicts))
+						assert.NoError(t, err)
+						mu.Lock()
+						totalConflicts += conflicts
+						mu.Unlock()
+					}
+				}()
+			}
+			wg.Wait()
+			t.Logf("conflicts: %d", totalConflicts)
+
+			response, err := etcd.NewSTM(context.Background(), client, func(stm etcd.STM) error {
+				value, err := stm.Get("stm_counter")
+				assert.NoError(t, err)
+				assert.Equal(t, strconv.Itoa(workers*increments), value)
+				stm.Del("stm_counter")
+				return nil
+			}, etcd.WithIsolation(tc.isolation), etcd.WithPrefetch("stm_counter"))
+			assert.NoError(t, err)
+			assert.True(t, response.Succeeded)
+		})
+	}
+
+	t.Run("MaxConflicts", func(t *testing.T) {
+		attempts := 0
+		_, err := etcd.NewSTM(context.Background(), client, func(stm etcd.STM) error {
+			attempts++
+			if _, err := stm.Get("stm_last"); err != nil {
+				return err
+			}
+			_, err := etcd.Put(context.Background(), client, &etcd.PutRequest{Key: "stm_last", Value: strconv.Itoa(attempts)})
+			return err
+		}, etcd.WithMaxConflicts(2))
+		assert.ErrorIs(t, err, etcd.ErrConflictLimit)
+		assert.Equal(t, 3, attempts)
+	})
+
+	syncRevision(t, client)
+	t.Run("TearDown", runTest(client, []TestCase{
+		{
+			request:  &etcd.DeleteRequest{Key: "stm_", RangeEnd: etcd.GetPrefix("stm_")},
+			response: &etcd.DeleteResponse{Deleted: 1, PrevKvs: []*etcd.KeyValue{}},
+		},
+		{
+			request:  &etcd.RangeRequest{Key: etcd.EmptyKey, RangeEnd: etcd.EmptyKey},
+			response: &etcd.RangeResponse{Count: 0, Kvs: []*etcd.KeyValue{}},
+		},
+	}))
+}
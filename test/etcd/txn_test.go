@@ -4,6 +4,7 @@ import (
 	"math"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
 
 	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
@@ -313,3 +314,137 @@ func TestTxn(t *testing.T) {
 		t.Run(tc.name, runTest(client, tc.testcases))
 	}
 }
+
+func mustBuild(b *etcd.TxnBuilder) *etcd.TxnRequest {
+	request, err := b.Build()
+	if err != nil {
+		panic(err)
+	}
+	return request
+}
+
+func TestTxnBuilder(t *testing.T) {
+	t.Run("Invalid", func(t *testing.T) {
+		for _, tc := range []struct {
+			name    string
+			builder *etcd.TxnBuilder
+			err     error
+		}{
+			{name: "IfAfterThen", builder: etcd.NewTxn().Then().If(), err: etcd.ErrInvalidTxn},
+			{name: "ThenAfterElse", builder: etcd.NewTxn().Else().Then(), err: etcd.ErrInvalidTxn},
+			{name: "IfAfterElse", builder: etcd.NewTxn().Else().If(), err: etcd.ErrInvalidTxn},
+			{name: "NoTarget", builder: etcd.NewTxn().If(etcd.Compare{Key: "txnb_a"}.Equal()), err: etcd.ErrCompareTarget},
+			{name: "TwoTargets", builder: etcd.NewTxn().If(etcd.Compare{Key: "txnb_a"}.Equal().SetVersion(1).SetValue("a")), err: etcd.ErrCompareTarget},
+			{name: "Compact", builder: etcd.NewTxn().Then(&etcd.CompactRequest{}), err: etcd.ErrInvalidTxn},
+			{name: "NilOp", builder: etcd.NewTxn().Then((*etcd.PutRequest)(nil)), err: etcd.ErrInvalidTxn},
+			{name: "Nested", builder: etcd.NewTxn().ElseTxn(etcd.NewTxn().If(etcd.Compare{Key: "txnb_a"})), err: etcd.ErrCompareTarget},
+		} {
+			t.Run(tc.name, func(t *testing.T) {
+				_, err := tc.builder.Build()
+				assert.ErrorIs(t, err, tc.err)
+			})
+		}
+	})
+
+	for _, tc := range []struct {
+		name      string
+		testcases []TestCase
+	}{
+		{
+			name: "SetUp",
+			testcases: []TestCase{
+				{
+					request:  &etcd.RangeRequest{Key: etcd.EmptyKey, RangeEnd: etcd.EmptyKey},
+					response: &etcd.RangeResponse{Count: 0, Kvs: []*etcd.KeyValue{}},
+				},
+				{
+					request: mustBuild(etcd.NewTxn().Then(
+						&etcd.PutRequest{Key: "txnb_a", Value: "a"},
+						&etcd.PutRequest{Key: "txnb_b", Value: "b"},
+					)),
+					response: &etcd.TxnResponse{
+						Succeeded: true,
+						Responses: []etcd.Response{&etcd.PutResponse{}, &etcd.PutResponse{}},
+					},
+				},
+			},
+		},
+		{
+			name: "Range Compare",
+			testcases: []TestCase{
+				{
+					request: mustBuild(etcd.NewTxn().
+						If(
+							etcd.Version("txnb_").WithPrefix().Equal(1),
+							etcd.Value("txnb_a").WithRange("txnb_b").Equal("a"),
+						).
+						Then(&etcd.PutRequest{Key: "txnb_c", Value: "c"}).
+						ThenTxn(etcd.NewTxn().
+							If(etcd.Value("txnb_b").Equal("x")).
+							Else(&etcd.RangeRequest{Key: "txnb_b"}),
+						).
+						Else(&etcd.DeleteRequest{Key: "txnb_a"}),
+					),
+					response: &etcd.TxnResponse{
+						Succeeded: true,
+						Responses: []etcd.Response{
+							&etcd.PutResponse{},
+							&etcd.TxnResponse{
+								Succeeded: false,
+								Responses: []etcd.Response{
+									&etcd.RangeResponse{
+										Count: 1,
+										Kvs: []*etcd.KeyValue{
+											{Key: "txnb_b", ModRevision: -1, CreateRevision: -1, Version: 1, Value: "b"},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+				{
+					request:  &etcd.PutRequest{Key: "txnb_a", Value: "a"},
+					response: &etcd.PutResponse{},
+				},
+				{
+					request: mustBuild(etcd.NewTxn().
+						If(etcd.Version("txnb_").WithPrefix().Equal(1)).
+						Else(&etcd.RangeRequest{Key: "txnb_", RangeEnd: etcd.GetPrefix("txnb_"), CountOnly: true}),
+					),
+					response: &etcd.TxnResponse{
+						Succeeded: false,
+						Responses: []etcd.Response{
+							&etcd.RangeResponse{Count: 3, Kvs: []*etcd.KeyValue{}},
+						},
+					},
+				},
+				{
+					request: mustBuild(etcd.NewTxn().
+						If(
+							etcd.ModRevision("txnb_").WithPrefix().Less(1),
+							etcd.CreateRevision("txnb_").WithPrefix().Greater(-3),
+							etcd.LeaseID("txnb_").WithPrefix().Equal(0),
+						),
+					),
+					response: &etcd.TxnResponse{Succeeded: true, Responses: []etcd.Response{}},
+				},
+			},
+		},
+		{
+			name: "TearDown",
+			testcases: []TestCase{
+				{
+					request:  &etcd.DeleteRequest{Key: "txnb_", RangeEnd: etcd.GetPrefix("txnb_")},
+					response: &etcd.DeleteResponse{Deleted: 3, PrevKvs: []*etcd.KeyValue{}},
+				},
+				{
+					request:  &etcd.RangeRequest{Key: etcd.EmptyKey, RangeEnd: etcd.EmptyKey},
+					response: &etcd.RangeResponse{Count: 0, Kvs: []*etcd.KeyValue{}},
+				},
+			},
+		},
+	} {
+		t.Run(tc.name, runTest(client, tc.testcases))
+	}
+}
@@ -18,19 +18,19 @@ type TestCase struct {
 	err      error
 }
 
-func runTest(client *etcd.Client, tcs []TestCase) func(*testing.T) {
+func runTest(kv etcd.KV, tcs []TestCase) func(*testing.T) {
 	return func(t *testing.T) {
 		for _, tc := range tcs {
-			runTestCase(t, client, tc)
+			runTestCase(t, kv, tc)
 		}
 	}
 }
 
-func runTestCase(t *testing.T, client *etcd.Client, tc TestCase) {
+func runTestCase(t *testing.T, kv etcd.KV, tc TestCase) {
 	t.Helper()
 	fillRequest(revision, tc.request)
 	fmt.Printf(" request = %#v\n", tc.request)
-	actual, err := etcd.Do(context.Background(), client, tc.request)
+	actual, err := etcd.Do(context.Background(), kv, tc.request)
 
 	if tc.err != nil {
 		assert.ErrorIs(t, err, tc.err)
@@ -92,3 +92,26 @@ func fillResponse(revision *int64, response etcd.Response) {
 		panic("unknown response type")
 	}
 }
+
+// runIsolatedTest runs test cases against a KV other than the shared client,
+// tracking its revisions separately.
+func runIsolatedTest(kv etcd.KV, tcs []TestCase) func(*testing.T) {
+	return func(t *testing.T) {
+		saved := revision
+		revision = nil
+		defer func() { revision = saved }()
+		runTest(kv, tcs)(t)
+	}
+}
+
+// syncRevision catches the expected revision up with writes made to the
+// shared client outside of runTestCase.
+func syncRevision(t *testing.T, kv etcd.KV) {
+	t.Helper()
+	response, err := etcd.Range(context.Background(), kv, &etcd.RangeRequest{Key: etcd.EmptyKey})
+	if !assert.NoError(t, err) {
+		return
+	}
+	currentRevision := response.Revision
+	revision = &currentRevision
+}
@@ -0,0 +1,75 @@
+package etcd_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/v3rpc/rpctypes"
+
+	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
+)
+
+type unknownRequest struct{}
+
+func (unknownRequest) Request() {}
+
+func TestValidate(t *testing.T) {
+	tooManyOps := make([]etcd.Request, etcd.MaxTxnOps+1)
+	for i := range tooManyOps {
+		tooManyOps[i] = &etcd.PutRequest{Key: "validate_" + strconv.Itoa(i)}
+	}
+
+	for _, tc := range []struct {
+		name    string
+		request etcd.Request
+		err     error
+	}{
+		{name: "Unknown", request: unknownRequest{}, err: etcd.ErrUnknownRequest},
+		{name: "Nil", request: (*etcd.RangeRequest)(nil), err: etcd.ErrUnknownRequest},
+		{name: "RangeEmptyKey", request: &etcd.RangeRequest{}, err: etcd.ErrEmptyKey},
+		{name: "PutEmptyKey", request: &etcd.PutRequest{Value: "validate_value"}, err: etcd.ErrEmptyKey},
+		{name: "DeleteEmptyKey", request: &etcd.DeleteRequest{RangeEnd: "validate_"}, err: etcd.ErrEmptyKey},
+		{
+			name:    "NestedEmptyKey",
+			request: &etcd.TxnRequest{Failure: []etcd.Request{&etcd.TxnRequest{Success: []etcd.Request{&etcd.PutRequest{}}}}},
+			err:     etcd.ErrEmptyKey,
+		},
+		{
+			name:    "CompareTarget",
+			request: &etcd.TxnRequest{Compare: []etcd.Compare{{Key: "validate_key"}}},
+			err:     etcd.ErrCompareTarget,
+		},
+		{
+			name:    "CompactInTxn",
+			request: &etcd.TxnRequest{Success: []etcd.Request{&etcd.CompactRequest{Revision: 1}}},
+			err:     etcd.ErrCompactInTxn,
+		},
+		{name: "TooManyOps", request: &etcd.TxnRequest{Success: tooManyOps}, err: etcd.ErrTooManyOps},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.ErrorIs(t, etcd.Validate(tc.request), tc.err)
+			_, err := etcd.Do(context.Background(), client, tc.request)
+			assert.ErrorIs(t, err, tc.err)
+		})
+	}
+
+	// Requests bypassing validation are still rejected by the server with the
+	// same errors.
+	t.Run("Server", func(t *testing.T) {
+		_, err := client.Put(context.Background(), &etcdserverpb.PutRequest{Value: []byte("validate_value")})
+		assert.ErrorIs(t, err, etcd.ErrEmptyKey)
+		assert.ErrorIs(t, err, rpctypes.ErrGRPCEmptyKey)
+
+		ops := make([]*etcdserverpb.RequestOp, etcd.MaxTxnOps+1)
+		for i := range ops {
+			ops[i] = &etcdserverpb.RequestOp{Request: &etcdserverpb.RequestOp_RequestRange{
+				RequestRange: &etcdserverpb.RangeRequest{Key: []byte("validate_" + strconv.Itoa(i))},
+			}}
+		}
+		_, err = client.Txn(context.Background(), &etcdserverpb.TxnRequest{Success: ops})
+		assert.ErrorIs(t, err, etcd.ErrTooManyOps)
+	})
+}
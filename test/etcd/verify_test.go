@@ -0,0 +1,250 @@
+package etcd_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
+	"github.com/ydb-platform/etcd-ydb/pkg/verify"
+)
+
+type verifyStep struct {
+	request  etcd.Request
+	response etcd.Response
+	err      error
+	kinds    []string
+}
+
+func runVerifySteps(t *testing.T, steps []verifyStep) {
+	t.Helper()
+	session := verify.New().Session()
+	for i, step := range steps {
+		var kinds []string
+		for _, anomaly := range session.Begin(step.request).End(step.response, step.err) {
+			kinds = append(kinds, anomaly.Kind)
+		}
+		assert.Equal(t, step.kinds, kinds, "step %d", i)
+	}
+}
+
+func verifyKv(key string, create, mod, version int64, value string) *etcd.KeyValue {
+	return &etcd.KeyValue{Key: key, CreateRevision: create, ModRevision: mod, Version: version, Value: value}
+}
+
+func TestVerifier(t *testing.T) {
+	put := &etcd.PutRequest{Key: "k", Value: "v1"}
+	get := &etcd.RangeRequest{Key: "k"}
+
+	t.Run("Consistent", func(t *testing.T) {
+		runVerifySteps(t, []verifyStep{
+			{request: put, response: &etcd.PutResponse{Revision: 2}},
+			{request: get, response: &etcd.RangeResponse{Revision: 2, Count: 1, Kvs: []*etcd.KeyValue{verifyKv("k", 2, 2, 1, "v1")}}},
+			{request: &etcd.PutRequest{Key: "k", Value: "v2"}, response: &etcd.PutResponse{Revision: 3}},
+			{request: get, response: &etcd.RangeResponse{Revision: 4, Count: 1, Kvs: []*etcd.KeyValue{verifyKv("k", 2, 3, 2, "v2")}}},
+			{request: &etcd.DeleteRequest{Key: "k"}, response: &etcd.DeleteResponse{Revision: 5, Deleted: 1}},
+			{request: get, response: &etcd.RangeResponse{Revision: 5, Kvs: []*etcd.KeyValue{}}},
+		})
+	})
+
+	t.Run("LostWrite", func(t *testing.T) {
+		runVerifySteps(t, []verifyStep{
+			{request: put, response: &etcd.PutResponse{Revision: 2}},
+			{request: &etcd.PutRequest{Key: "k", Value: "v2"}, response: &etcd.PutResponse{Revision: 3}},
+			{request: get, response: &etcd.RangeResponse{Revision: 3, Count: 1, Kvs: []*etcd.KeyValue{verifyKv("k", 2, 2, 1, "v1")}}, kinds: []string{verify.LostWrite}},
+		})
+		runVerifySteps(t, []verifyStep{
+			{request: put, response: &etcd.PutResponse{Revision: 2}},
+			{request: get, response: &etcd.RangeResponse{Revision: 2, Kvs: []*etcd.KeyValue{}}, kinds: []string{verify.LostWrite}},
+		})
+		runVerifySteps(t, []verifyStep{
+			{request: put, response: &etcd.PutResponse{Revision: 2}},
+			{request: &etcd.DeleteRequest{Key: "k"}, response: &etcd.DeleteResponse{Revision: 3, Deleted: 1}},
+			{request: get, response: &etcd.RangeResponse{Revision: 3, Count: 1, Kvs: []*etcd.KeyValue{verifyKv("k", 2, 2, 1, "v1")}}, kinds: []string{verify.LostWrite}},
+		})
+	})
+
+	t.Run("UncertainDelete", func(t *testing.T) {
+		runVerifySteps(t, []verifyStep{
+			{request: put, response: &etcd.PutResponse{Revision: 2}},
+			{request: &etcd.DeleteRequest{Key: "k"}, err: context.DeadlineExceeded},
+			{request: get, response: &etcd.RangeResponse{Revision: 3, Kvs: []*etcd.KeyValue{}}},
+			// A failed delete does not hide the writes after it.
+			{request: put, response: &etcd.PutResponse{Revision: 4}},
+			{request: get, response: &etcd.RangeResponse{Revision: 4, Kvs: []*etcd.KeyValue{}}, kinds: []string{verify.LostWrite}},
+		})
+	})
+
+	t.Run("RangeDelete", func(t *testing.T) {
+		runVerifySteps(t, []verifyStep{
+			{request: put, response: &etcd.PutResponse{Revision: 2}},
+			{request: &etcd.DeleteRequest{Key: "a", RangeEnd: "z"}, response: &etcd.DeleteResponse{Revision: 3, Deleted: 1}},
+			{request: get, response: &etcd.RangeResponse{Revision: 3, Kvs: []*etcd.KeyValue{}}},
+		})
+	})
+
+	t.Run("ValueMismatch", func(t *testing.T) {
+		runVerifySteps(t, []verifyStep{
+			{request: put, response: &etcd.PutResponse{Revision: 2}},
+			{request: get, response: &etcd.RangeResponse{Revision: 2, Count: 1, Kvs: []*etcd.KeyValue{verifyKv("k", 2, 2, 1, "corrupt")}}, kinds: []string{verify.ValueMismatch}},
+			{request: &etcd.RangeRequest{Key: "k", KeysOnly: true}, response: &etcd.RangeResponse{Revision: 2, Count: 1, Kvs: []*etcd.KeyValue{verifyKv("k", 2, 2, 1, "")}}},
+		})
+	})
+
+	t.Run("InvalidVersion", func(t *testing.T) {
+		runVerifySteps(t, []verifyStep{
+			{request: get, response: &etcd.RangeResponse{Revision: 3, Count: 1, Kvs: []*etcd.KeyValue{verifyKv("k", 2, 2, 2, "v")}}, kinds: []string{verify.InvalidVersion}},
+			{request: get, response: &etcd.RangeResponse{Revision: 4, Count: 1, Kvs: []*etcd.KeyValue{verifyKv("k", 2, 4, 3, "v")}}},
+			{request: get, response: &etcd.RangeResponse{Revision: 5, Count: 1, Kvs: []*etcd.KeyValue{verifyKv("k", 2, 5, 3, "v")}}, kinds: []string{verify.InvalidVersion}},
+		})
+	})
+
+	t.Run("InvalidRevision", func(t *testing.T) {
+		runVerifySteps(t, []verifyStep{
+			{request: get, response: &etcd.RangeResponse{Revision: 3, Count: 1, Kvs: []*etcd.KeyValue{verifyKv("k", 2, 4, 1, "v")}}, kinds: []string{verify.InvalidRevision}},
+			{request: get, response: &etcd.RangeResponse{Revision: 5, Count: 1, Kvs: []*etcd.KeyValue{verifyKv("k", 5, 4, 1, "v")}}, kinds: []string{verify.InvalidRevision}},
+		})
+	})
+
+	t.Run("StaleRead", func(t *testing.T) {
+		runVerifySteps(t, []verifyStep{
+			{request: get, response: &etcd.RangeResponse{Revision: 5, Count: 1, Kvs: []*etcd.KeyValue{verifyKv("k", 2, 5, 3, "v")}}},
+			{request: get, response: &etcd.RangeResponse{Revision: 4, Count: 1, Kvs: []*etcd.KeyValue{verifyKv("k", 2, 4, 2, "v")}}, kinds: []string{verify.NonMonotonicRevision, verify.StaleRead, verify.StaleRead}},
+		})
+
+		v := verify.New()
+		s1, s2 := v.Session(), v.Session()
+		assert.Empty(t, s1.Begin(put).End(&etcd.PutResponse{Revision: 7}, nil))
+		anomalies := s2.Begin(get).End(&etcd.RangeResponse{Revision: 6, Kvs: []*etcd.KeyValue{}}, nil)
+		var kinds []string
+		for _, anomaly := range anomalies {
+			kinds = append(kinds, anomaly.Kind)
+		}
+		assert.Equal(t, []string{verify.StaleRead, verify.LostWrite}, kinds)
+	})
+
+	t.Run("NonMonotonicRevision", func(t *testing.T) {
+		runVerifySteps(t, []verifyStep{
+			{request: put, response: &etcd.PutResponse{Revision: 3}},
+			{request: &etcd.PutRequest{Key: "other", Value: "v"}, response: &etcd.PutResponse{Revision: 3}, kinds: []string{verify.NonMonotonicRevision}},
+		})
+	})
+
+	t.Run("Concurrent", func(t *testing.T) {
+		v := verify.New()
+		s1, s2 := v.Session(), v.Session()
+		// A read racing with a write may see either state.
+		read := s2.Begin(get)
+		assert.Empty(t, s1.Begin(put).End(&etcd.PutResponse{Revision: 2}, nil))
+		assert.Empty(t, read.End(&etcd.RangeResponse{Revision: 1, Kvs: []*etcd.KeyValue{}}, nil))
+	})
+
+	t.Run("RangeDeleteRacingWrite", func(t *testing.T) {
+		v := verify.New()
+		s1, s2, s3 := v.Session(), v.Session(), v.Session()
+		// A write acknowledged after a range delete may still precede it.
+		write := s1.Begin(put)
+		assert.Empty(t, s2.Begin(&etcd.DeleteRequest{Key: "a", RangeEnd: "z"}).End(&etcd.DeleteResponse{Revision: 3, Deleted: 1}, nil))
+		assert.Empty(t, write.End(&etcd.PutResponse{Revision: 2}, nil))
+		assert.Empty(t, s3.Begin(get).End(&etcd.RangeResponse{Revision: 3, Kvs: []*etcd.KeyValue{}}, nil))
+		// Once no write may precede the delete, it is forgotten.
+		assert.Empty(t, s1.Begin(put).End(&etcd.PutResponse{Revision: 4}, nil))
+		anomalies := s3.Begin(get).End(&etcd.RangeResponse{Revision: 4, Kvs: []*etcd.KeyValue{}}, nil)
+		if assert.Len(t, anomalies, 1) {
+			assert.Equal(t, verify.LostWrite, anomalies[0].Kind)
+		}
+	})
+
+	t.Run("Txn", func(t *testing.T) {
+		runVerifySteps(t, []verifyStep{
+			{
+				request:  &etcd.TxnRequest{Success: []etcd.Request{put}},
+				response: &etcd.TxnResponse{Revision: 2, Succeeded: true, Responses: []etcd.Response{&etcd.PutResponse{Revision: 2}}},
+			},
+			{
+				request:  &etcd.TxnRequest{Failure: []etcd.Request{get}},
+				response: &etcd.TxnResponse{Revision: 2, Responses: []etcd.Response{&etcd.RangeResponse{Revision: 2, Kvs: []*etcd.KeyValue{}}}},
+				kinds:    []string{verify.LostWrite},
+			},
+		})
+	})
+}
+
+// runVerifiedWorkload runs random requests on a few keys from concurrent
+// sessions and returns the anomalies found.
+func runVerifiedWorkload(t *testing.T, kv etcd.KV, prefix string) []verify.Anomaly {
+	t.Helper()
+	const workers, iterations, keys = 4, 100, 4
+	v := verify.New()
+	var mu sync.Mutex
+	var anomalies []verify.Anomaly
+	var wg sync.WaitGroup
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			session := v.Session()
+			for i := range iterations {
+				key := fmt.Sprintf("%s%d", prefix, rand.Intn(keys))
+				var request etcd.Request
+				switch rand.Intn(5) {
+				case 0, 1:
+					request = &etcd.RangeRequest{Key: key}
+				case 2:
+					request = &etcd.PutRequest{Key: key, Value: fmt.Sprintf("%d-%d", w, i)}
+				case 3:
+					request = &etcd.DeleteRequest{Key: key}
+				case 4:
+					request = &etcd.TxnRequest{
+						Compare: []etcd.Compare{etcd.Value(key).Equal(fmt.Sprintf("%d-%d", w, i-1))},
+						Success: []etcd.Request{&etcd.PutRequest{Key: key, Value: fmt.Sprintf("%d-%d", w, i)}},
+						Failure: []etcd.Request{&etcd.RangeRequest{Key: prefix, RangeEnd: etcd.GetPrefix(prefix)}},
+					}
+				}
+				check := session.Begin(request)
+				response, err := etcd.Do(context.Background(), kv, request)
+				if !assert.NoError(t, err) {
+					return
+				}
+				found := check.End(response, err)
+				mu.Lock()
+				anomalies = append(anomalies, found...)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	return anomalies
+}
+
+func TestVerifiedWorkload(t *testing.T) {
+	t.Run("MemoryKV", func(t *testing.T) {
+		assert.Empty(t, runVerifiedWorkload(t, etcd.NewMemoryKV(), "verify_"))
+	})
+
+	t.Run("SetUp", runTest(client, []TestCase{
+		{
+			request:  &etcd.RangeRequest{Key: etcd.EmptyKey, RangeEnd: etcd.EmptyKey},
+			response: &etcd.RangeResponse{Count: 0, Kvs: []*etcd.KeyValue{}},
+		},
+	}))
+
+	t.Run("Server", func(t *testing.T) {
+		assert.Empty(t, runVerifiedWorkload(t, client, "verify_"))
+		_, err := etcd.Delete(context.Background(), client, &etcd.DeleteRequest{Key: "verify_", RangeEnd: etcd.GetPrefix("verify_")})
+		require.NoError(t, err)
+	})
+
+	syncRevision(t, client)
+	t.Run("TearDown", runTest(client, []TestCase{
+		{
+			request:  &etcd.RangeRequest{Key: etcd.EmptyKey, RangeEnd: etcd.EmptyKey},
+			response: &etcd.RangeResponse{Count: 0, Kvs: []*etcd.KeyValue{}},
+		},
+	}))
+}
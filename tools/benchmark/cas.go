@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cheggaaa/pb/v3"
+	"github.com/spf13/cobra"
+
+	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
+	"github.com/ydb-platform/etcd-ydb/pkg/report"
+)
+
+var casCmd = &cobra.Command{
+	Use:  "cas",
+	RunE: casFunc,
+}
+
+var (
+	casTotal        uint64
+	casRateLimit    uint64
+	casKeySize      uint64
+	casValSize      uint64
+	casKeySpaceSize uint64
+	casMaxAttempts  uint64
+)
+
+func init() {
+	RootCmd.AddCommand(casCmd)
+	casCmd.Flags().Uint64Var(&casTotal, "total", 10000, "Total number of commits")
+	casCmd.Flags().Uint64Var(&casRateLimit, "rate-limit", math.MaxUint64, "Maximum commits per second")
+	casCmd.Flags().Uint64Var(&casKeySize, "key-size", 8, "Key size of request")
+	casCmd.Flags().Uint64Var(&casValSize, "val-size", 8, "Value size of request")
+	casCmd.Flags().Uint64Var(&casKeySpaceSize, "key-space-size", 1, "Maximum possible keys, the fewer the more contended")
+	casCmd.Flags().Uint64Var(&casMaxAttempts, "max-attempts", 0, "Maximum txns of a commit before it fails, 0 means no limit")
+}
+
+var errTooManyConflicts = errors.New("cas: too many conflicts")
+
+type casStats struct {
+	report.Stats
+	Clients uint
+	Keys    uint64
+	// Attempts counts the txns sent and Conflicts those that failed their
+	// compare. SuccessRate is the share of attempts that committed and
+	// ConflictsPerCommit the failed attempts per commit.
+	Attempts           int64
+	Conflicts          int64
+	SuccessRate        float64
+	ConflictsPerCommit float64
+}
+
+// casCommit reads key and swaps its value with a txn on its mod revision,
+// retrying with the revision read by the failed txn until it commits. The
+// value must be unique: a failed txn that reads it back was a retry of an
+// attempt that committed.
+func casCommit(ctx context.Context, client *etcd.Client, key, value string, attempts, conflicts *atomic.Int64) error {
+	read, err := etcd.Range(ctx, client, &etcd.RangeRequest{Key: key})
+	if err != nil {
+		return err
+	}
+	for attempt := uint64(1); ; attempt++ {
+		var modRevision int64
+		if len(read.Kvs) != 0 {
+			modRevision = read.Kvs[0].ModRevision
+		}
+		attempts.Add(1)
+		response, err := etcd.Txn(ctx, client, &etcd.TxnRequest{
+			Compare: []etcd.Compare{etcd.ModRevision(key).Equal(modRevision)},
+			Success: []etcd.Request{&etcd.PutRequest{Key: key, Value: value}},
+			Failure: []etcd.Request{&etcd.RangeRequest{Key: key}},
+		})
+		if err != nil {
+			return err
+		}
+		if response.Succeeded {
+			return nil
+		}
+		read = response.Responses[0].(*etcd.RangeResponse)
+		if len(read.Kvs) != 0 && read.Kvs[0].Value == value {
+			return nil
+		}
+		conflicts.Add(1)
+		if casMaxAttempts > 0 && attempt >= casMaxAttempts {
+			return errTooManyConflicts
+		}
+	}
+}
+
+func casFunc(_ *cobra.Command, _ []string) error {
+	if casValSize < minUniqueValueSize {
+		return fmt.Errorf("value size %d must be at least %d to tell own writes apart", casValSize, minUniqueValueSize)
+	}
+	clients, err := newClients()
+	if err != nil {
+		return err
+	}
+	limit := newLimiter(casRateLimit)
+
+	bar := pb.New64(int64(casTotal))
+	bar.Start()
+
+	ops := make(chan string, totalClients)
+	rep := newReport()
+	var attempts, conflicts atomic.Int64
+	var wg sync.WaitGroup
+	for i := range clients {
+		wg.Add(1)
+		go func(client *etcd.Client) {
+			defer wg.Done()
+			for key := range ops {
+				limit.Wait(context.Background())
+				value := uniqueValue(casValSize)
+				var retries int
+				ctx := etcd.WithRetries(context.Background(), &retries)
+				start := time.Now()
+				err := casCommit(ctx, client, key, value, &attempts, &conflicts)
+				rep.Results() <- report.Result{TotalTime: time.Since(start), Retries: retries, Err: err}
+				bar.Increment()
+			}
+		}(clients[i])
+	}
+
+	go func() {
+		key := []byte(strings.Repeat("-", int(casKeySize)))
+		for range casTotal {
+			fillKey(key, rand.Uint64()%casKeySpaceSize)
+			ops <- string(key)
+		}
+		close(ops)
+	}()
+
+	rc := rep.Run()
+	wg.Wait()
+	close(rep.Results())
+	bar.Finish()
+	stats := casStats{
+		Stats:     <-rc,
+		Clients:   totalClients,
+		Keys:      casKeySpaceSize,
+		Attempts:  attempts.Load(),
+		Conflicts: conflicts.Load(),
+	}
+	if stats.Attempts > 0 {
+		stats.SuccessRate = float64(stats.Total) / float64(stats.Attempts)
+	}
+	if stats.Total > 0 {
+		stats.ConflictsPerCommit = float64(stats.Conflicts) / float64(stats.Total)
+	}
+	return printStats(stats)
+}
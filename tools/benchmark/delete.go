@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/bits"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cheggaaa/pb/v3"
+	"github.com/spf13/cobra"
+
+	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
+)
+
+var deleteCmd = &cobra.Command{
+	Use:  "delete",
+	RunE: deleteFunc,
+}
+
+// Delete modes.
+const (
+	deleteKey     = "key"
+	deletePrefix  = "prefix"
+	deleteFromKey = "from-key"
+)
+
+// deleteKeyPrefix starts every key of the benchmark, so that deletes from a
+// key stay within its keys.
+const deleteKeyPrefix = "delete/"
+
+var (
+	deleteTotal        uint64
+	deleteRateLimit    uint64
+	deleteKeySize      uint64
+	deleteValSize      uint64
+	deleteKeySpaceSize uint64
+	deleteMode         string
+	deletePrefixSize   uint64
+	deleteRangeSize    uint64
+	deletePrevKv       bool
+	deletePreload      bool
+)
+
+func init() {
+	RootCmd.AddCommand(deleteCmd)
+	deleteCmd.Flags().Uint64Var(&deleteTotal, "total", 10000, "Total number of requests")
+	deleteCmd.Flags().Uint64Var(&deleteRateLimit, "rate-limit", math.MaxUint64, "Maximum requests per second")
+	deleteCmd.Flags().Uint64Var(&deleteKeySize, "key-size", 8, "Key size of request after the \""+deleteKeyPrefix+"\" prefix")
+	deleteCmd.Flags().Uint64Var(&deleteValSize, "val-size", 8, "Value size of preloaded keys")
+	deleteCmd.Flags().Uint64Var(&deleteKeySpaceSize, "key-space-size", 10000, "Maximum possible keys in key mode")
+	deleteCmd.Flags().StringVar(&deleteMode, "mode", deleteKey, "Keys deleted by a request (key, prefix, from-key)")
+	deleteCmd.Flags().Uint64Var(&deletePrefixSize, "prefix-size", 4, "Size of the prefix of the keys deleted by a request in prefix and from-key modes")
+	deleteCmd.Flags().Uint64Var(&deleteRangeSize, "range-size", 10, "Number of keys deleted by a request in prefix and from-key modes")
+	deleteCmd.Flags().BoolVar(&deletePrevKv, "prev-kv", false, "Return the deleted key-value pairs")
+	deleteCmd.Flags().BoolVar(&deletePreload, "preload", true, "Put every key of the key space before the deletes")
+}
+
+// fillDeleteKey fills key with the n-th key of the key space. In the prefix
+// and from-key modes the keys deleted by the i-th request are the range-size
+// keys starting with i zero-padded to prefix-size after deleteKeyPrefix, so
+// that no prefix is the prefix of another and the prefixes sort as the
+// requests.
+func fillDeleteKey(key []byte, n uint64) {
+	key = key[copy(key, deleteKeyPrefix):]
+	if deleteMode == deleteKey {
+		fillKey(key, n)
+		return
+	}
+	prefix := strconv.FormatUint(n/deleteRangeSize, 10)
+	copy(key, strings.Repeat("0", int(deletePrefixSize)-len(prefix))+prefix)
+	fillKey(key[deletePrefixSize:], n%deleteRangeSize)
+}
+
+// deleteRequest returns the delete of the n-th key of the key space in key
+// mode, or of the keys of the n-th request in the other modes.
+func deleteRequest(key []byte, n uint64) *etcd.DeleteRequest {
+	if deleteMode == deleteKey {
+		fillDeleteKey(key, n)
+		return &etcd.DeleteRequest{Key: string(key), PrevKv: deletePrevKv}
+	}
+	fillDeleteKey(key, n*deleteRangeSize)
+	request := &etcd.DeleteRequest{Key: string(key), PrevKv: deletePrevKv}
+	switch deleteMode {
+	case deletePrefix:
+		request.Key = string(key[:len(deleteKeyPrefix)+int(deletePrefixSize)])
+		request.RangeEnd = etcd.GetPrefix(request.Key)
+	case deleteFromKey:
+		request.RangeEnd = etcd.GetPrefix(deleteKeyPrefix)
+	}
+	return request
+}
+
+// deleteKeys returns the size of the key space to preload.
+func deleteKeys() (uint64, error) {
+	if deleteMode == deleteKey {
+		if deleteKeySpaceSize < 1 {
+			return 0, fmt.Errorf("invalid key space size %d", deleteKeySpaceSize)
+		}
+		if deleteTotal > deleteKeySpaceSize {
+			return 0, fmt.Errorf("total %d must not exceed key space size %d to delete existing keys", deleteTotal, deleteKeySpaceSize)
+		}
+		if uint64(len(strconv.FormatUint(deleteKeySpaceSize-1, 10))) > deleteKeySize {
+			return 0, fmt.Errorf("key size %d is too small for %d keys", deleteKeySize, deleteKeySpaceSize)
+		}
+		return deleteKeySpaceSize, nil
+	}
+	if deleteRangeSize < 1 {
+		return 0, fmt.Errorf("invalid range size %d", deleteRangeSize)
+	}
+	if uint64(len(strconv.FormatUint(max(deleteTotal, 1)-1, 10))) > deletePrefixSize ||
+		uint64(len(strconv.FormatUint(deleteRangeSize-1, 10))) > deleteKeySize-min(deletePrefixSize, deleteKeySize) {
+		return 0, fmt.Errorf("key size %d with prefix size %d is too small for %d requests of %d keys", deleteKeySize, deletePrefixSize, deleteTotal, deleteRangeSize)
+	}
+	if deleteTotal > math.MaxUint64/deleteRangeSize {
+		return 0, fmt.Errorf("too many keys for %d requests of %d keys", deleteTotal, deleteRangeSize)
+	}
+	return deleteTotal * deleteRangeSize, nil
+}
+
+// permutation returns a function mapping the i-th request to a key of a key
+// space of size keys, visiting every key once before any repeats so that
+// deletes of single keys hit existing ones.
+func permutation(keys uint64) func(i uint64) uint64 {
+	gcd := func(a, b uint64) uint64 {
+		for b != 0 {
+			a, b = b, a%b
+		}
+		return a
+	}
+	start, stride := rand.Uint64()%keys, rand.Uint64()%keys+1
+	for gcd(stride, keys) != 1 {
+		stride++
+	}
+	return func(i uint64) uint64 {
+		// The product overflows 64 bits in large key spaces.
+		hi, lo := bits.Mul64(i%keys, stride%keys)
+		return (start + bits.Rem64(hi, lo, keys)) % keys
+	}
+}
+
+func deleteFunc(_ *cobra.Command, _ []string) error {
+	switch deleteMode {
+	case deleteKey, deletePrefix, deleteFromKey:
+	default:
+		return fmt.Errorf("unknown delete mode %q", deleteMode)
+	}
+	keys, err := deleteKeys()
+	if err != nil {
+		return err
+	}
+	clients, err := newClients()
+	if err != nil {
+		return err
+	}
+	if deletePreload {
+		if err := preload(clients, keys, fillDeleteKey, uint64(len(deleteKeyPrefix))+deleteKeySize, deleteValSize, defaultLoadBatch); err != nil {
+			return err
+		}
+	}
+	limit := newLimiter(deleteRateLimit)
+
+	bar := pb.New64(int64(deleteTotal))
+	bar.Start()
+
+	ops := make(chan etcd.Request, totalClients)
+	rep := newReport()
+	var wg sync.WaitGroup
+	for i := range clients {
+		wg.Add(1)
+		go func(client *etcd.Client) {
+			defer wg.Done()
+			session := newSession()
+			for op := range ops {
+				limit.Wait(context.Background())
+				rep.Results() <- do(client, session, op)
+				bar.Increment()
+			}
+		}(clients[i])
+	}
+
+	go func() {
+		key := []byte(strings.Repeat("-", len(deleteKeyPrefix)+int(deleteKeySize)))
+		var next func(i uint64) uint64
+		switch deleteMode {
+		case deleteKey:
+			next = permutation(keys)
+		case deletePrefix:
+			next = permutation(max(deleteTotal, 1))
+		default:
+			// A delete from a key removes the keys of the requests after
+			// it, so they go last to first.
+			next = func(i uint64) uint64 { return deleteTotal - 1 - i }
+		}
+		for i := range deleteTotal {
+			ops <- deleteRequest(key, next(i))
+		}
+		close(ops)
+	}()
+
+	rc := rep.Run()
+	wg.Wait()
+	close(rep.Results())
+	bar.Finish()
+	stats := <-rc
+	return printStats(stats)
+}
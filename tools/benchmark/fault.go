@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
+	"github.com/ydb-platform/etcd-ydb/pkg/proxy"
+)
+
+// startFaultProxy serves a proxy to the endpoints that injects the faults of
+// the schedule and returns its address. The schedule starts right away.
+func startFaultProxy(schedule string) (string, error) {
+	steps, err := proxy.ParseSchedule(schedule)
+	if err != nil {
+		return "", err
+	}
+	target, err := etcd.NewClient(endpoints, etcd.WithBalancer(balancer), etcd.WithUserAgent("etcd-ydb-benchmark"))
+	if err != nil {
+		return "", err
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		target.Close()
+		return "", err
+	}
+	p := proxy.New(target.Conn())
+	go func() {
+		if err := p.Serve(lis); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}()
+	go func() {
+		if err := p.RunSchedule(context.Background(), steps); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+	}()
+	return lis.Addr().String(), nil
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cheggaaa/pb/v3"
+	"github.com/spf13/cobra"
+	"golang.org/x/time/rate"
+
+	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
+	"github.com/ydb-platform/etcd-ydb/pkg/report"
+)
+
+var k8sCmd = &cobra.Command{
+	Use:  "k8s",
+	RunE: k8sFunc,
+}
+
+var (
+	k8sResources          []string
+	k8sObjects            uint64
+	k8sObjectSize         uint64
+	k8sChurn              float64
+	k8sListRate           float64
+	k8sPageSize           int64
+	k8sEventRate          float64
+	k8sEventTTL           time.Duration
+	k8sLeaseReuse         time.Duration
+	k8sCompactionInterval time.Duration
+	k8sDuration           time.Duration
+)
+
+func init() {
+	RootCmd.AddCommand(k8sCmd)
+	k8sCmd.Flags().StringSliceVar(&k8sResources, "resources", []string{"pods", "configmaps", "services", "nodes"}, "Resources whose objects are kept under their own prefix and watched")
+	k8sCmd.Flags().Uint64Var(&k8sObjects, "objects", 1000, "Number of objects of every resource")
+	k8sCmd.Flags().Uint64Var(&k8sObjectSize, "object-size", 1024, "Size of a serialized object")
+	k8sCmd.Flags().Float64Var(&k8sChurn, "churn", 100, "Object updates per second")
+	k8sCmd.Flags().Float64Var(&k8sListRate, "list-rate", 1, "Paginated lists of a resource per second")
+	k8sCmd.Flags().Int64Var(&k8sPageSize, "page-size", 500, "Limit of a list page")
+	k8sCmd.Flags().Float64Var(&k8sEventRate, "event-rate", 50, "Events with a lease created per second")
+	k8sCmd.Flags().DurationVar(&k8sEventTTL, "event-ttl", time.Hour, "TTL of events")
+	k8sCmd.Flags().DurationVar(&k8sLeaseReuse, "lease-reuse", time.Minute, "Time a lease is attached to new events for")
+	k8sCmd.Flags().DurationVar(&k8sCompactionInterval, "compaction-interval", 5*time.Minute, "Interval of compactions to the revision of the previous one, 0 disables them")
+	k8sCmd.Flags().DurationVar(&k8sDuration, "duration", time.Minute, "Duration of the churn after the objects are created")
+}
+
+// k8sStats are the stats of every kind of apiserver request.
+type k8sStats struct {
+	Create report.Stats
+	Update report.Stats
+	List   report.Stats
+	Event  report.Stats
+	// Conflicts counts the updates that failed their compare and were
+	// retried, Compactions the compactions done, WatchEvents the events
+	// received by the watches of the resources and WatchFailures the watches
+	// whose stream failed.
+	Conflicts     int64
+	Compactions   int
+	WatchEvents   int64
+	WatchFailures int64
+}
+
+func k8sKey(resource string, n uint64) string {
+	return fmt.Sprintf("/registry/%s/default/object-%d", resource, n)
+}
+
+func k8sPrefix(resource string) string {
+	return fmt.Sprintf("/registry/%s/", resource)
+}
+
+// k8sCache keeps the mod revision of every object, like the watch cache of
+// apiserver does, to update the objects without reading them first.
+type k8sCache struct {
+	mu           sync.Mutex
+	modRevisions map[string]int64
+}
+
+func (c *k8sCache) get(key string) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.modRevisions[key]
+}
+
+func (c *k8sCache) set(key string, modRevision int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.modRevisions[key] = max(c.modRevisions[key], modRevision)
+}
+
+// k8sCreate creates key if it does not exist yet.
+func k8sCreate(ctx context.Context, client *etcd.Client, cache *k8sCache, key, value string) error {
+	response, err := etcd.Txn(ctx, client, &etcd.TxnRequest{
+		Compare: []etcd.Compare{etcd.ModRevision(key).Equal(0)},
+		Success: []etcd.Request{&etcd.PutRequest{Key: key, Value: value}},
+		Failure: []etcd.Request{&etcd.RangeRequest{Key: key}},
+	})
+	if err != nil {
+		return err
+	}
+	if response.Succeeded {
+		cache.set(key, response.Revision)
+	} else if kvs := response.Responses[0].(*etcd.RangeResponse).Kvs; len(kvs) != 0 {
+		cache.set(key, kvs[0].ModRevision)
+	}
+	return nil
+}
+
+// k8sUpdate updates key on the mod revision of the cache and retries with the
+// revision read by the failed txn, as the guaranteed updates of apiserver do.
+// The value must be unique: a failed txn that reads it back was a retry of an
+// attempt that committed.
+func k8sUpdate(ctx context.Context, client *etcd.Client, cache *k8sCache, key, value string, conflicts *atomic.Int64) error {
+	modRevision := cache.get(key)
+	for {
+		response, err := etcd.Txn(ctx, client, &etcd.TxnRequest{
+			Compare: []etcd.Compare{etcd.ModRevision(key).Equal(modRevision)},
+			Success: []etcd.Request{&etcd.PutRequest{Key: key, Value: value}},
+			Failure: []etcd.Request{&etcd.RangeRequest{Key: key}},
+		})
+		if err != nil {
+			return err
+		}
+		if response.Succeeded {
+			cache.set(key, response.Revision)
+			return nil
+		}
+		kvs := response.Responses[0].(*etcd.RangeResponse).Kvs
+		if len(kvs) == 0 {
+			return fmt.Errorf("k8s: object %s not found", key)
+		}
+		if kvs[0].Value == value {
+			cache.set(key, kvs[0].ModRevision)
+			return nil
+		}
+		conflicts.Add(1)
+		modRevision = kvs[0].ModRevision
+		cache.set(key, modRevision)
+	}
+}
+
+// k8sList lists the objects of a resource in pages, all at the revision of
+// the first one.
+func k8sList(ctx context.Context, client *etcd.Client, resource string) error {
+	prefix := k8sPrefix(resource)
+	request := &etcd.RangeRequest{Key: prefix, RangeEnd: etcd.GetPrefix(prefix), Limit: k8sPageSize}
+	for {
+		response, err := etcd.Range(ctx, client, request)
+		if err != nil {
+			return err
+		}
+		if !response.More || len(response.Kvs) == 0 {
+			return nil
+		}
+		request.Revision = response.Revision
+		request.Key = response.Kvs[len(response.Kvs)-1].Key + "\x00"
+	}
+}
+
+// k8sLeases attaches events to a lease for the reuse time before granting a
+// new one, like the lease manager of apiserver.
+type k8sLeases struct {
+	mu      sync.Mutex
+	id      int64
+	expires time.Time
+}
+
+func (l *k8sLeases) get(ctx context.Context, client *etcd.Client) (int64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if time.Now().Before(l.expires) {
+		return l.id, nil
+	}
+	ttl := (k8sEventTTL + k8sLeaseReuse).Seconds()
+	response, err := etcd.LeaseGrant(ctx, client, &etcd.LeaseGrantRequest{TTL: int64(ttl)})
+	if err != nil {
+		return 0, err
+	}
+	l.id, l.expires = response.ID, time.Now().Add(k8sLeaseReuse)
+	return l.id, nil
+}
+
+type k8sOp struct {
+	kind string
+	key  string
+}
+
+// Kinds of k8s ops.
+const (
+	k8sOpCreate = "create"
+	k8sOpUpdate = "update"
+	k8sOpList   = "list"
+	k8sOpEvent  = "event"
+)
+
+func k8sFunc(_ *cobra.Command, _ []string) error {
+	if len(k8sResources) == 0 || k8sObjects < 1 || k8sPageSize < 1 {
+		return fmt.Errorf("invalid resources %v, objects %d or page size %d", k8sResources, k8sObjects, k8sPageSize)
+	}
+	if k8sObjectSize < minUniqueValueSize {
+		return fmt.Errorf("object size %d must be at least %d to tell own updates apart", k8sObjectSize, minUniqueValueSize)
+	}
+	if loadScheduleSpec != "" {
+		return fmt.Errorf("the churn cannot follow a load schedule")
+	}
+	clients, err := newClients()
+	if err != nil {
+		return err
+	}
+	cache := &k8sCache{modRevisions: make(map[string]int64)}
+	leases := &k8sLeases{}
+	var conflicts, watchEvents, watchFailures atomic.Int64
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var watchers sync.WaitGroup
+	for i, resource := range k8sResources {
+		prefix := k8sPrefix(resource)
+		events, err := etcd.Watch(ctx, clients[i%len(clients)], &etcd.WatchRequest{Key: prefix, RangeEnd: etcd.GetPrefix(prefix)})
+		if err != nil {
+			return err
+		}
+		watchers.Add(1)
+		go func() {
+			defer watchers.Done()
+			for response := range events {
+				if response.Err != nil {
+					watchFailures.Add(1)
+					return
+				}
+				watchEvents.Add(int64(len(response.Events)))
+				for _, event := range response.Events {
+					cache.set(event.Kv.Key, event.Kv.ModRevision)
+				}
+			}
+		}()
+	}
+
+	reports := map[string]report.Report{
+		k8sOpCreate: report.NewReport(totalClients),
+		k8sOpUpdate: report.NewReport(totalClients),
+		k8sOpList:   report.NewReport(totalClients),
+		k8sOpEvent:  report.NewReport(totalClients),
+	}
+	rcs := make(map[string]<-chan report.Stats)
+	for kind, rep := range reports {
+		rcs[kind] = rep.Run()
+	}
+	ops := make(chan k8sOp, totalClients)
+	var events atomic.Uint64
+	var wg sync.WaitGroup
+	for i := range clients {
+		wg.Add(1)
+		go func(client *etcd.Client) {
+			defer wg.Done()
+			value := strings.Repeat("-", int(k8sObjectSize))
+			for op := range ops {
+				var update string
+				if op.kind == k8sOpUpdate {
+					update = uniqueValue(k8sObjectSize)
+				}
+				reports[op.kind].Results() <- timed(func(ctx context.Context) error {
+					switch op.kind {
+					case k8sOpCreate:
+						return k8sCreate(ctx, client, cache, op.key, value)
+					case k8sOpUpdate:
+						return k8sUpdate(ctx, client, cache, op.key, update, &conflicts)
+					case k8sOpList:
+						return k8sList(ctx, client, op.key)
+					default:
+						id, err := leases.get(ctx, client)
+						if err != nil {
+							return err
+						}
+						key := fmt.Sprintf("/registry/events/default/event-%d", events.Add(1))
+						_, err = etcd.Put(ctx, client, &etcd.PutRequest{Key: key, Value: value, Lease: id})
+						return err
+					}
+				})
+			}
+		}(clients[i])
+	}
+
+	bar := pb.New64(int64(k8sObjects) * int64(len(k8sResources)))
+	bar.Start()
+	for n := range k8sObjects {
+		for _, resource := range k8sResources {
+			ops <- k8sOp{kind: k8sOpCreate, key: k8sKey(resource, n)}
+			bar.Increment()
+		}
+	}
+	bar.Finish()
+
+	churn, churnCancel := context.WithTimeout(ctx, k8sDuration)
+	defer churnCancel()
+	var generators sync.WaitGroup
+	generate := func(limit float64, next func() k8sOp) {
+		if limit <= 0 {
+			return
+		}
+		generators.Add(1)
+		go func() {
+			defer generators.Done()
+			limiter := rate.NewLimiter(rate.Limit(limit), 1)
+			for limiter.Wait(churn) == nil {
+				select {
+				case ops <- next():
+				case <-churn.Done():
+					return
+				}
+			}
+		}()
+	}
+	randomResource := func() string { return k8sResources[rand.Intn(len(k8sResources))] }
+	generate(k8sChurn, func() k8sOp {
+		return k8sOp{kind: k8sOpUpdate, key: k8sKey(randomResource(), rand.Uint64()%k8sObjects)}
+	})
+	generate(k8sListRate, func() k8sOp { return k8sOp{kind: k8sOpList, key: randomResource()} })
+	generate(k8sEventRate, func() k8sOp { return k8sOp{kind: k8sOpEvent} })
+
+	var compactions int
+	if k8sCompactionInterval > 0 {
+		generators.Add(1)
+		go func() {
+			defer generators.Done()
+			ticker := time.NewTicker(k8sCompactionInterval)
+			defer ticker.Stop()
+			var previous int64
+			for {
+				select {
+				case <-ticker.C:
+				case <-churn.Done():
+					return
+				}
+				current, err := etcd.Range(churn, clients[0], &etcd.RangeRequest{Key: etcd.EmptyKey, Limit: 1, CountOnly: true})
+				if err != nil {
+					continue
+				}
+				if previous > 0 {
+					if _, err := etcd.Compact(churn, clients[0], &etcd.CompactRequest{Revision: previous}); err == nil {
+						compactions++
+					}
+				}
+				previous = current.Revision
+			}
+		}()
+	}
+
+	generators.Wait()
+	close(ops)
+	wg.Wait()
+	for _, rep := range reports {
+		close(rep.Results())
+	}
+	cancel()
+	watchers.Wait()
+
+	stats := k8sStats{
+		Create:        <-rcs[k8sOpCreate],
+		Update:        <-rcs[k8sOpUpdate],
+		List:          <-rcs[k8sOpList],
+		Event:         <-rcs[k8sOpEvent],
+		Conflicts:     conflicts.Load(),
+		Compactions:   compactions,
+		WatchEvents:   watchEvents.Load(),
+		WatchFailures: watchFailures.Load(),
+	}
+	return printStats(stats)
+}
@@ -0,0 +1,383 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cheggaaa/pb/v3"
+	"github.com/spf13/cobra"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+	"go.etcd.io/etcd/api/v3/mvccpb"
+
+	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
+	"github.com/ydb-platform/etcd-ydb/pkg/report"
+)
+
+var leaseGrantCmd = &cobra.Command{
+	Use:  "lease-grant",
+	RunE: leaseGrantFunc,
+}
+
+var leaseKeepAliveCmd = &cobra.Command{
+	Use:  "lease-keepalive",
+	RunE: leaseKeepAliveFunc,
+}
+
+var leaseExpiryCmd = &cobra.Command{
+	Use:  "lease-expiry",
+	RunE: leaseExpiryFunc,
+}
+
+var (
+	leaseGrantTotal     uint64
+	leaseGrantRateLimit uint64
+	leaseGrantTTL       int64
+	leaseGrantRevoke    bool
+
+	leaseKeepAliveStreams  int
+	leaseKeepAliveTTL      int64
+	leaseKeepAliveInterval time.Duration
+	leaseKeepAliveDuration time.Duration
+
+	leaseExpiryLeases       uint64
+	leaseExpiryKeysPerLease uint64
+	leaseExpiryTTL          int64
+	leaseExpiryKeySize      uint64
+	leaseExpiryValSize      uint64
+	leaseExpiryTimeout      time.Duration
+)
+
+func init() {
+	RootCmd.AddCommand(leaseGrantCmd)
+	leaseGrantCmd.Flags().Uint64Var(&leaseGrantTotal, "total", 10000, "Total number of leases")
+	leaseGrantCmd.Flags().Uint64Var(&leaseGrantRateLimit, "rate-limit", math.MaxUint64, "Maximum grants per second")
+	leaseGrantCmd.Flags().Int64Var(&leaseGrantTTL, "ttl", 60, "TTL of granted leases in seconds")
+	leaseGrantCmd.Flags().BoolVar(&leaseGrantRevoke, "revoke", true, "Revoke every lease after its grant")
+
+	RootCmd.AddCommand(leaseKeepAliveCmd)
+	leaseKeepAliveCmd.Flags().IntVar(&leaseKeepAliveStreams, "streams", 100, "Number of concurrent keep-alive streams, each of its own lease")
+	leaseKeepAliveCmd.Flags().Int64Var(&leaseKeepAliveTTL, "ttl", 10, "TTL of kept alive leases in seconds")
+	leaseKeepAliveCmd.Flags().DurationVar(&leaseKeepAliveInterval, "interval", time.Second, "Interval between keep-alives of a stream")
+	leaseKeepAliveCmd.Flags().DurationVar(&leaseKeepAliveDuration, "duration", 10*time.Second, "Duration of the benchmark")
+
+	RootCmd.AddCommand(leaseExpiryCmd)
+	leaseExpiryCmd.Flags().Uint64Var(&leaseExpiryLeases, "leases", 1000, "Number of leases expiring together")
+	leaseExpiryCmd.Flags().Uint64Var(&leaseExpiryKeysPerLease, "keys-per-lease", 1, "Number of keys attached to every lease")
+	leaseExpiryCmd.Flags().Int64Var(&leaseExpiryTTL, "ttl", 5, "TTL of the leases in seconds")
+	leaseExpiryCmd.Flags().Uint64Var(&leaseExpiryKeySize, "key-size", 8, "Key size of attached keys")
+	leaseExpiryCmd.Flags().Uint64Var(&leaseExpiryValSize, "val-size", 8, "Value size of attached keys")
+	leaseExpiryCmd.Flags().DurationVar(&leaseExpiryTimeout, "timeout", time.Minute, "Time to wait for the attached keys to be removed after the TTL")
+}
+
+// timed runs f and returns its result for a report.
+func timed(f func(ctx context.Context) error) report.Result {
+	var retries int
+	ctx := etcd.WithRetries(context.Background(), &retries)
+	start := time.Now()
+	err := f(ctx)
+	return report.Result{TotalTime: time.Since(start), Retries: retries, Err: err}
+}
+
+type leaseGrantStats struct {
+	Grant  report.Stats
+	Revoke report.Stats
+}
+
+func leaseGrantFunc(_ *cobra.Command, _ []string) error {
+	clients, err := newClients()
+	if err != nil {
+		return err
+	}
+	limit := newLimiter(leaseGrantRateLimit)
+
+	bar := pb.New64(int64(leaseGrantTotal))
+	bar.Start()
+
+	ops := make(chan struct{}, totalClients)
+	// The limit paces the grants, revokes follow them.
+	grants, revokes := newReport(), report.NewReport(totalClients)
+	var wg sync.WaitGroup
+	for i := range clients {
+		wg.Add(1)
+		go func(client *etcd.Client) {
+			defer wg.Done()
+			for range ops {
+				limit.Wait(context.Background())
+				var id int64
+				result := timed(func(ctx context.Context) error {
+					response, err := etcd.LeaseGrant(ctx, client, &etcd.LeaseGrantRequest{TTL: leaseGrantTTL})
+					if err == nil {
+						id = response.ID
+					}
+					return err
+				})
+				grants.Results() <- result
+				if result.Err == nil && leaseGrantRevoke {
+					revokes.Results() <- timed(func(ctx context.Context) error {
+						_, err := etcd.LeaseRevoke(ctx, client, &etcd.LeaseRevokeRequest{ID: id})
+						return err
+					})
+				}
+				bar.Increment()
+			}
+		}(clients[i])
+	}
+
+	go func() {
+		for range leaseGrantTotal {
+			ops <- struct{}{}
+		}
+		close(ops)
+	}()
+
+	grantc, revokec := grants.Run(), revokes.Run()
+	wg.Wait()
+	close(grants.Results())
+	close(revokes.Results())
+	bar.Finish()
+	stats := leaseGrantStats{Grant: <-grantc, Revoke: <-revokec}
+	return printStats(stats)
+}
+
+type leaseKeepAliveStats struct {
+	// Stats are those of the keep-alive round trips.
+	report.Stats
+	Streams int
+	// Expired counts the streams whose lease expired while kept alive.
+	Expired int
+}
+
+// keepAlive refreshes the lease id every interval on a single stream until
+// ctx is done and reports the round trip of every refresh. It reports
+// whether the lease expired.
+func keepAlive(ctx context.Context, client *etcd.Client, id int64, rep report.Report) (bool, error) {
+	stream, err := client.LeaseKeepAlive(ctx)
+	if err != nil {
+		return false, err
+	}
+	ticker := time.NewTicker(leaseKeepAliveInterval)
+	defer ticker.Stop()
+	for {
+		start := time.Now()
+		err := stream.Send(&etcdserverpb.LeaseKeepAliveRequest{ID: id})
+		var response *etcdserverpb.LeaseKeepAliveResponse
+		if err == nil {
+			response, err = stream.Recv()
+		}
+		if ctx.Err() != nil {
+			return false, nil
+		}
+		rep.Results() <- report.Result{TotalTime: time.Since(start), Err: err}
+		if err != nil {
+			return false, err
+		}
+		if response.TTL <= 0 {
+			return true, nil
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return false, nil
+		}
+	}
+}
+
+func leaseKeepAliveFunc(_ *cobra.Command, _ []string) error {
+	if leaseKeepAliveStreams < 1 || leaseKeepAliveInterval <= 0 {
+		return fmt.Errorf("invalid streams %d or interval %v", leaseKeepAliveStreams, leaseKeepAliveInterval)
+	}
+	if loadScheduleSpec != "" {
+		return fmt.Errorf("the keep-alives are paced by the interval, not a load schedule")
+	}
+	clients, err := newClients()
+	if err != nil {
+		return err
+	}
+	ids := make([]int64, leaseKeepAliveStreams)
+	for i := range ids {
+		response, err := etcd.LeaseGrant(context.Background(), clients[i%len(clients)], &etcd.LeaseGrantRequest{TTL: leaseKeepAliveTTL})
+		if err != nil {
+			return err
+		}
+		ids[i] = response.ID
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), leaseKeepAliveDuration)
+	defer cancel()
+	rep := report.NewReport(totalClients)
+	var mu sync.Mutex
+	var expired int
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		go func(client *etcd.Client) {
+			defer wg.Done()
+			lost, err := keepAlive(ctx, client, id, rep)
+			mu.Lock()
+			defer mu.Unlock()
+			if lost {
+				expired++
+			}
+			if err != nil {
+				rep.Results() <- report.Result{Err: err}
+			}
+		}(clients[i%len(clients)])
+	}
+
+	rc := rep.Run()
+	wg.Wait()
+	close(rep.Results())
+	stats := leaseKeepAliveStats{Stats: <-rc, Streams: leaseKeepAliveStreams, Expired: expired}
+	for i, id := range ids {
+		if _, err := etcd.LeaseRevoke(context.Background(), clients[i%len(clients)], &etcd.LeaseRevokeRequest{ID: id}); err != nil && expired == 0 {
+			return err
+		}
+	}
+	return printStats(stats)
+}
+
+type leaseExpiryStats struct {
+	// Stats are those of the grants and the puts of attached keys.
+	report.Stats
+	Leases uint64
+	Keys   uint64
+	// Removed counts the attached keys deleted by the expiry of their lease
+	// within the timeout. Lag is the time from the TTL of a lease ending to
+	// the removal of every key attached to it and Storm the time from the
+	// first removal to the last.
+	Removed int
+	Lag     []report.Percentile
+	Storm   time.Duration
+}
+
+const leaseExpiryPrefix = "lease_expiry/"
+
+func leaseExpiryFunc(_ *cobra.Command, _ []string) error {
+	if leaseExpiryLeases < 1 || leaseExpiryKeysPerLease < 1 {
+		return fmt.Errorf("invalid leases %d or keys per lease %d", leaseExpiryLeases, leaseExpiryKeysPerLease)
+	}
+	if loadScheduleSpec != "" {
+		return fmt.Errorf("the expiry cannot follow a load schedule")
+	}
+	clients, err := newClients()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := etcd.Watch(ctx, clients[0], &etcd.WatchRequest{
+		Key:      leaseExpiryPrefix,
+		RangeEnd: etcd.GetPrefix(leaseExpiryPrefix),
+		NoPut:    true,
+	})
+	if err != nil {
+		return err
+	}
+
+	bar := pb.New64(int64(leaseExpiryLeases))
+	bar.Start()
+
+	leases := make(chan uint64, totalClients)
+	rep := report.NewReport(totalClients)
+	var mu sync.Mutex
+	// expiries maps the keys attached to every lease to the end of its TTL.
+	expiries := make(map[string]time.Time)
+	var wg sync.WaitGroup
+	for i := range clients {
+		wg.Add(1)
+		go func(client *etcd.Client) {
+			defer wg.Done()
+			key, value := []byte(strings.Repeat("-", int(leaseExpiryKeySize))), strings.Repeat("-", int(leaseExpiryValSize))
+			for n := range leases {
+				var id, ttl int64
+				result := timed(func(ctx context.Context) error {
+					response, err := etcd.LeaseGrant(ctx, client, &etcd.LeaseGrantRequest{TTL: leaseExpiryTTL})
+					if err == nil {
+						// The server may grant another TTL than requested.
+						id, ttl = response.ID, response.TTL
+					}
+					return err
+				})
+				rep.Results() <- result
+				if result.Err != nil {
+					continue
+				}
+				expiry := time.Now().Add(time.Duration(ttl) * time.Second)
+				for j := range leaseExpiryKeysPerLease {
+					fillKey(key, n*leaseExpiryKeysPerLease+j)
+					attached := leaseExpiryPrefix + string(key)
+					result := timed(func(ctx context.Context) error {
+						_, err := etcd.Put(ctx, client, &etcd.PutRequest{Key: attached, Value: value, Lease: id})
+						return err
+					})
+					rep.Results() <- result
+					if result.Err == nil {
+						mu.Lock()
+						expiries[attached] = expiry
+						mu.Unlock()
+					}
+				}
+				bar.Increment()
+			}
+		}(clients[i])
+	}
+
+	go func() {
+		for n := range leaseExpiryLeases {
+			leases <- n
+		}
+		close(leases)
+	}()
+
+	rc := rep.Run()
+	wg.Wait()
+	close(rep.Results())
+	bar.Finish()
+	stats := leaseExpiryStats{Stats: <-rc, Leases: leaseExpiryLeases, Keys: uint64(len(expiries))}
+
+	var last time.Time
+	for _, expiry := range expiries {
+		last = maxTime(last, expiry)
+	}
+	timeout := time.After(time.Until(last) + leaseExpiryTimeout)
+	var lags []time.Duration
+	var first, latest time.Time
+	for remaining := len(expiries); remaining > 0; {
+		var response *etcd.WatchResponse
+		var ok bool
+		select {
+		case response, ok = <-events:
+		case <-timeout:
+		}
+		if !ok {
+			break
+		}
+		if response.Err != nil {
+			return fmt.Errorf("watch: %w", response.Err)
+		}
+		now := time.Now()
+		for _, event := range response.Events {
+			expiry, ok := expiries[event.Kv.Key]
+			if event.Type != mvccpb.DELETE || !ok {
+				continue
+			}
+			delete(expiries, event.Kv.Key)
+			remaining--
+			lags = append(lags, now.Sub(expiry))
+			if first.IsZero() {
+				first = now
+			}
+			latest = now
+		}
+	}
+	stats.Removed = len(lags)
+	stats.Storm = latest.Sub(first)
+	slices.Sort(lags)
+	stats.Lag = report.Percentiles(lags)
+	return printStats(stats)
+}
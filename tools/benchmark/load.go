@@ -0,0 +1,339 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cheggaaa/pb/v3"
+	"github.com/spf13/cobra"
+	"golang.org/x/time/rate"
+
+	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
+	"github.com/ydb-platform/etcd-ydb/pkg/report"
+)
+
+var loadCmd = &cobra.Command{
+	Use:  "load",
+	RunE: loadFunc,
+}
+
+// defaultLoadBatch is the number of puts per txn of a load or preload.
+const defaultLoadBatch = 16
+
+var (
+	loadKeys        uint64
+	loadSize        string
+	loadKeySize     uint64
+	loadValSize     uint64
+	loadBatch       uint64
+	loadRateLimit   uint64
+	loadCheckpoints int
+	loadWorkloads   []string
+	loadState       string
+)
+
+func init() {
+	RootCmd.AddCommand(loadCmd)
+	loadCmd.Flags().Uint64Var(&loadKeys, "keys", 0, "Number of keys to load")
+	loadCmd.Flags().StringVar(&loadSize, "size", "", "Dataset size to load instead of a number of keys, e.g. 16GB")
+	loadCmd.Flags().Uint64Var(&loadKeySize, "key-size", 8, "Key size of loaded entries")
+	loadCmd.Flags().Uint64Var(&loadValSize, "val-size", 8, "Value size of loaded entries")
+	loadCmd.Flags().Uint64Var(&loadBatch, "batch", defaultLoadBatch, "Number of puts per txn")
+	loadCmd.Flags().Uint64Var(&loadRateLimit, "rate-limit", math.MaxUint64, "Maximum txns per second, the workloads follow the load schedule instead if set")
+	loadCmd.Flags().IntVar(&loadCheckpoints, "checkpoints", 1, "Number of equal steps to load in, the workloads run after each of them")
+	loadCmd.Flags().StringArrayVar(&loadWorkloads, "workload", nil, "Workload to measure at every checkpoint, e.g. \"range --total=40000\"; may be repeated")
+	loadCmd.Flags().StringVar(&loadState, "state", "", "File to keep the progress in to resume an interrupted load")
+}
+
+// loadProgress is the resumable state of a load. Keys counts the loaded
+// prefix of the key space and Measured the keys of the last checkpoint whose
+// workloads ran.
+type loadProgress struct {
+	KeySize  uint64
+	ValSize  uint64
+	Keys     uint64
+	Measured uint64
+}
+
+func readProgress(name string) (loadProgress, error) {
+	progress := loadProgress{KeySize: loadKeySize, ValSize: loadValSize}
+	if name == "" {
+		return progress, nil
+	}
+	data, err := os.ReadFile(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return progress, nil
+	} else if err != nil {
+		return progress, err
+	}
+	var saved loadProgress
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return progress, fmt.Errorf("%s: %w", name, err)
+	}
+	if saved.KeySize != loadKeySize || saved.ValSize != loadValSize {
+		return progress, fmt.Errorf("%s: loaded with key size %d and value size %d", name, saved.KeySize, saved.ValSize)
+	}
+	return saved, nil
+}
+
+func writeProgress(name string, progress loadProgress) error {
+	if name == "" {
+		return nil
+	}
+	data, err := json.Marshal(progress)
+	if err != nil {
+		return err
+	}
+	tmp := name + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, name)
+}
+
+// parseSize parses a number of bytes with an optional decimal or binary unit.
+func parseSize(s string) (uint64, error) {
+	units := []struct {
+		suffix string
+		scale  float64
+	}{
+		{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+		{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+		{"B", 1},
+	}
+	scale := 1.0
+	for _, unit := range units {
+		if number, ok := strings.CutSuffix(s, unit.suffix); ok {
+			s, scale = number, unit.scale
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return uint64(n * scale), nil
+}
+
+func loadTarget() (uint64, error) {
+	if (loadKeys == 0) == (loadSize == "") {
+		return 0, fmt.Errorf("exactly one of --keys and --size must be set")
+	}
+	if loadKeys != 0 {
+		return loadKeys, nil
+	}
+	size, err := parseSize(loadSize)
+	if err != nil {
+		return 0, err
+	}
+	return size / (loadKeySize + loadValSize), nil
+}
+
+func loadFunc(_ *cobra.Command, _ []string) error {
+	target, err := loadTarget()
+	if err != nil {
+		return err
+	}
+	if loadCheckpoints < 1 || loadBatch < 1 || loadBatch > uint64(etcd.MaxTxnOps) {
+		return fmt.Errorf("invalid checkpoints %d or batch %d", loadCheckpoints, loadBatch)
+	}
+	if uint64(len(strconv.FormatUint(target, 10))) > loadKeySize {
+		return fmt.Errorf("key size %d is too small for %d keys", loadKeySize, target)
+	}
+	progress, err := readProgress(loadState)
+	if err != nil {
+		return err
+	}
+	clients, err := newClients()
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	for checkpoint := 1; checkpoint <= loadCheckpoints; checkpoint++ {
+		keys := target * uint64(checkpoint) / uint64(loadCheckpoints)
+		if keys <= progress.Measured {
+			continue
+		}
+		if progress.Keys < keys {
+			if err := loadKeyRange(ctx, clients, &progress, checkpoint, keys); err != nil {
+				return err
+			}
+		}
+		for _, workload := range loadWorkloads {
+			metadata = &Metadata{
+				Workload:     workload,
+				Checkpoint:   checkpoint,
+				Keys:         progress.Keys,
+				DatasetBytes: progress.Keys * (loadKeySize + loadValSize),
+			}
+			defaults := map[string]uint64{"key-space-size": max(progress.Keys, 1), "key-size": loadKeySize, "val-size": loadValSize}
+			if _, err := runWorkload("load", strings.Fields(workload), defaults); err != nil {
+				return fmt.Errorf("workload %q: %w", workload, err)
+			}
+		}
+		progress.Measured = keys
+		if err := writeProgress(loadState, progress); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadKeyRange puts the keys from progress.Keys up to end in batches and
+// prints the report of the load. It saves the progress every second, so an
+// interrupted load resumes from the last keys known to be loaded.
+func loadKeyRange(ctx context.Context, clients []*etcd.Client, progress *loadProgress, checkpoint int, end uint64) error {
+	limit := rate.NewLimiter(rate.Limit(loadRateLimit), 1)
+	bar := pb.New64(int64(end - progress.Keys))
+	bar.Start()
+	rep := report.NewReport(totalClients)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := putKeys(ctx, clients, rep, limit, progress.Keys, end, fillKey, loadKeySize, loadValSize, loadBatch)
+
+	// Batches finish out of order, the progress only covers the keys below
+	// the first batch not finished yet.
+	rc := rep.Run()
+	finished := make(map[uint64]bool)
+	var failed bool
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for running := true; running; {
+		select {
+		case first, ok := <-done:
+			if !ok {
+				running = false
+				break
+			}
+			if first == math.MaxUint64 {
+				failed = true
+				continue
+			}
+			bar.Add64(int64(min(first+loadBatch, end) - first))
+			finished[first] = true
+			for finished[progress.Keys] {
+				delete(finished, progress.Keys)
+				progress.Keys = min(progress.Keys+loadBatch, end)
+			}
+		case <-ticker.C:
+			if err := writeProgress(loadState, *progress); err != nil {
+				// The workers block until their batches are received.
+				cancel()
+				for range done {
+				}
+				close(rep.Results())
+				bar.Finish()
+				<-rc
+				return err
+			}
+		}
+	}
+	close(rep.Results())
+	bar.Finish()
+
+	metadata = &Metadata{
+		Workload:     "load",
+		Checkpoint:   checkpoint,
+		Keys:         progress.Keys,
+		DatasetBytes: progress.Keys * (loadKeySize + loadValSize),
+	}
+	if err := printStats(<-rc); err != nil {
+		return err
+	}
+	if err := writeProgress(loadState, *progress); err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if failed || progress.Keys < end {
+		return fmt.Errorf("loaded %d of %d keys", progress.Keys, end)
+	}
+	return nil
+}
+
+// putKeys puts the keys from start up to end, numbered by fill, in txns of
+// batch puts. It sends the first key of every batch to the returned channel
+// once the batch is loaded, or math.MaxUint64 if it failed, and closes the
+// channel when all batches are done or ctx is done.
+func putKeys(ctx context.Context, clients []*etcd.Client, rep report.Report, limit *rate.Limiter, start, end uint64, fill func(key []byte, n uint64), keySize, valSize, batch uint64) <-chan uint64 {
+	batches := make(chan uint64, totalClients)
+	done := make(chan uint64, totalClients)
+	var wg sync.WaitGroup
+	for i := range clients {
+		wg.Add(1)
+		go func(client *etcd.Client) {
+			defer wg.Done()
+			key, value := make([]byte, keySize), strings.Repeat("-", int(valSize))
+			for first := range batches {
+				if limit.Wait(ctx) != nil {
+					continue
+				}
+				last := min(first+batch, end)
+				success := make([]etcd.Request, 0, last-first)
+				for n := first; n < last; n++ {
+					fill(key, n)
+					success = append(success, &etcd.PutRequest{Key: string(key), Value: value})
+				}
+				result := do(client, nil, &etcd.TxnRequest{Success: success})
+				rep.Results() <- result
+				if result.Err == nil {
+					done <- first
+				} else {
+					done <- math.MaxUint64
+				}
+			}
+		}(clients[i])
+	}
+
+	go func() {
+		defer close(batches)
+		for first := start; first < end; first += batch {
+			select {
+			case batches <- first:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	return done
+}
+
+// preload puts the keys below end, numbered by fill, unmeasured, for a
+// workload that needs them to exist.
+func preload(clients []*etcd.Client, end uint64, fill func(key []byte, n uint64), keySize, valSize, batch uint64) error {
+	bar := pb.New64(int64(end))
+	bar.Start()
+	rep := report.NewReport(totalClients)
+	rc := rep.Run()
+	var failed int
+	for first := range putKeys(context.Background(), clients, rep, rate.NewLimiter(rate.Inf, 1), 0, end, fill, keySize, valSize, batch) {
+		if first == math.MaxUint64 {
+			failed++
+			continue
+		}
+		bar.Add64(int64(min(first+batch, end) - first))
+	}
+	close(rep.Results())
+	bar.Finish()
+	<-rc
+	if failed > 0 {
+		return fmt.Errorf("preload: %d batches failed", failed)
+	}
+	return nil
+}
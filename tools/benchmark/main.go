@@ -3,9 +3,12 @@ package main
 import (
 	"fmt"
 	"os"
+
+	"google.golang.org/grpc/grpclog"
 )
 
 func main() {
+	grpclog.SetLoggerV2(grpclog.NewLoggerV2(os.Stderr, os.Stderr, os.Stderr))
 	if err := RootCmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
 		os.Exit(-1)
@@ -2,22 +2,16 @@ package main
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 	"math"
 	"math/rand"
-	"os"
 	"slices"
 	"strings"
 	"sync"
-	"time"
 
 	"github.com/cheggaaa/pb/v3"
 	"github.com/spf13/cobra"
-	"golang.org/x/time/rate"
 
 	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
-	"github.com/ydb-platform/etcd-ydb/pkg/report"
 )
 
 var mixedCmd = &cobra.Command{
@@ -49,25 +43,23 @@ func mixedFunc(_ *cobra.Command, _ []string) error {
 	if err != nil {
 		return err
 	}
-	limit := rate.NewLimiter(rate.Limit(mixedRateLimit), 1)
+	limit := newLimiter(mixedRateLimit)
 
 	mixedTotal = uint64(float64(mixedTotal) / (1 - mixedReadRatio))
 	bar := pb.New64(int64(mixedTotal))
 	bar.Start()
 
 	ops := make(chan etcd.Request, totalClients)
-	rep := report.NewReport(totalClients)
+	rep := newReport()
 	var wg sync.WaitGroup
 	for i := range clients {
 		wg.Add(1)
 		go func(client *etcd.Client) {
 			defer wg.Done()
+			session := newSession()
 			for op := range ops {
 				limit.Wait(context.Background())
-
-				start := time.Now()
-				_, err := etcd.Do(context.Background(), client, op)
-				rep.Results() <- report.Result{TotalTime: time.Since(start), Err: err}
+				rep.Results() <- do(client, session, op)
 				bar.Increment()
 			}
 		}(clients[i])
@@ -99,11 +91,5 @@ func mixedFunc(_ *cobra.Command, _ []string) error {
 	close(rep.Results())
 	bar.Finish()
 	stats := <-rc
-	fmt.Fprintf(os.Stderr, "%#v\n", stats)
-	data, err := json.Marshal(stats)
-	if err != nil {
-		return err
-	}
-	fmt.Println(string(data))
-	return nil
+	return printStats(stats)
 }
@@ -2,22 +2,16 @@ package main
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 	"math"
 	"math/rand"
-	"os"
 	"slices"
 	"strings"
 	"sync"
-	"time"
 
 	"github.com/cheggaaa/pb/v3"
 	"github.com/spf13/cobra"
-	"golang.org/x/time/rate"
 
 	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
-	"github.com/ydb-platform/etcd-ydb/pkg/report"
 )
 
 var rangeCmd = &cobra.Command{
@@ -47,24 +41,22 @@ func rangeFunc(_ *cobra.Command, _ []string) error {
 	if err != nil {
 		return err
 	}
-	limit := rate.NewLimiter(rate.Limit(rangeRateLimit), 1)
+	limit := newLimiter(rangeRateLimit)
 
 	bar := pb.New64(int64(rangeTotal))
 	bar.Start()
 
 	ops := make(chan etcd.Request, totalClients)
-	rep := report.NewReport(totalClients)
+	rep := newReport()
 	var wg sync.WaitGroup
 	for i := range clients {
 		wg.Add(1)
 		go func(client *etcd.Client) {
 			defer wg.Done()
+			session := newSession()
 			for op := range ops {
 				limit.Wait(context.Background())
-
-				start := time.Now()
-				_, err := etcd.Do(context.Background(), client, op)
-				rep.Results() <- report.Result{TotalTime: time.Since(start), Err: err}
+				rep.Results() <- do(client, session, op)
 				bar.Increment()
 			}
 		}(clients[i])
@@ -90,11 +82,5 @@ func rangeFunc(_ *cobra.Command, _ []string) error {
 	close(rep.Results())
 	bar.Finish()
 	stats := <-rc
-	fmt.Fprintf(os.Stderr, "%#v\n", stats)
-	data, err := json.Marshal(stats)
-	if err != nil {
-		return err
-	}
-	fmt.Println(string(data))
-	return nil
+	return printStats(stats)
 }
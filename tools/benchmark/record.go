@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
+	"github.com/ydb-platform/etcd-ydb/pkg/proxy"
+)
+
+var recordCmd = &cobra.Command{
+	Use:  "record",
+	RunE: recordFunc,
+}
+
+var (
+	recordListen   string
+	recordOutput   string
+	recordDuration time.Duration
+)
+
+func init() {
+	RootCmd.AddCommand(recordCmd)
+	recordCmd.Flags().StringVar(&recordListen, "listen", "127.0.0.1:23790", "Address to serve the recording proxy on")
+	recordCmd.Flags().StringVar(&recordOutput, "output", "trace.jsonl", "File to write the recorded calls to, one JSON record per line")
+	recordCmd.Flags().DurationVar(&recordDuration, "duration", 0, "Time to record for, 0 means until interrupted")
+}
+
+type recordStats struct {
+	Total   int
+	Skipped int
+	Methods map[string]int
+	Errors  map[string]int
+}
+
+func recordFunc(_ *cobra.Command, _ []string) error {
+	clients, err := newClients()
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(recordOutput)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	w := bufio.NewWriter(file)
+	enc := json.NewEncoder(w)
+
+	stats := recordStats{Methods: make(map[string]int), Errors: make(map[string]int)}
+	var mu sync.Mutex
+	var encodeErr error
+	observe := func(call proxy.Call) {
+		method, ok := etcd.KVMethod(call.Method)
+		mu.Lock()
+		defer mu.Unlock()
+		if !ok || !call.Unary() {
+			stats.Skipped++
+			return
+		}
+		rec, err := etcd.UnmarshalRecord(method, call.Request, call.Response)
+		if err == nil {
+			rec.Start, rec.Duration, rec.Err = call.Start, call.Duration, call.Err
+			err = enc.Encode(rec)
+		}
+		if err != nil {
+			if encodeErr == nil {
+				encodeErr = err
+			}
+			return
+		}
+		stats.Total++
+		stats.Methods[method]++
+		if call.Err != nil {
+			stats.Errors[call.Err.Error()]++
+		}
+	}
+
+	lis, err := net.Listen("tcp", recordListen)
+	if err != nil {
+		return err
+	}
+	p := proxy.New(clients[0].Conn(), proxy.WithObserver(observe))
+	fmt.Fprintf(os.Stderr, "recording calls to %s on %s\n", recordOutput, lis.Addr())
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if recordDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, recordDuration)
+		defer cancel()
+	}
+	served := make(chan error, 1)
+	go func() { served <- p.Serve(lis) }()
+	select {
+	case err = <-served:
+	case <-ctx.Done():
+		p.Stop()
+		<-served
+	}
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if encodeErr != nil {
+		return encodeErr
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return printStats(stats)
+}
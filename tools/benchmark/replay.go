@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cheggaaa/pb/v3"
+	"github.com/spf13/cobra"
+
+	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
+	"github.com/ydb-platform/etcd-ydb/pkg/report"
+)
+
+var replayCmd = &cobra.Command{
+	Use:  "replay",
+	RunE: replayFunc,
+}
+
+var (
+	replayInput string
+	replaySpeed float64
+)
+
+func init() {
+	RootCmd.AddCommand(replayCmd)
+	replayCmd.Flags().StringVar(&replayInput, "input", "trace.jsonl", "File with the calls written by record")
+	replayCmd.Flags().Float64Var(&replaySpeed, "speed", 1, "Speed relative to the recorded timing, 0 means as fast as possible")
+}
+
+type replayStats struct {
+	report.Stats
+	Speed float64
+	// Recorded are the latency percentiles of the recorded calls and Delta
+	// those of the replayed latency minus the recorded one of each call.
+	Recorded []report.Percentile
+	Delta    []report.Percentile
+	// MaxLag is the longest a call started behind its schedule.
+	MaxLag time.Duration
+	// OutcomeMismatches counts calls that failed on one side only.
+	OutcomeMismatches int
+}
+
+func readRecords(name string) ([]etcd.Record, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	var records []etcd.Record
+	dec := json.NewDecoder(file)
+	for {
+		var rec etcd.Record
+		if err := dec.Decode(&rec); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("record %d: %w", len(records)+1, err)
+		}
+		records = append(records, rec)
+	}
+	slices.SortStableFunc(records, func(a, b etcd.Record) int { return a.Start.Compare(b.Start) })
+	return records, nil
+}
+
+func replayFunc(_ *cobra.Command, _ []string) error {
+	if replaySpeed < 0 {
+		return fmt.Errorf("invalid speed %v", replaySpeed)
+	}
+	if loadScheduleSpec != "" {
+		return fmt.Errorf("the replay follows the recorded times, not a load schedule")
+	}
+	records, err := readRecords(replayInput)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("no records in %s", replayInput)
+	}
+	clients, err := newClients()
+	if err != nil {
+		return err
+	}
+
+	bar := pb.New(len(records))
+	bar.Start()
+
+	type scheduled struct {
+		rec *etcd.Record
+		at  time.Time
+	}
+	ops := make(chan scheduled, totalClients)
+	rep := report.NewReport(totalClients)
+	var mu sync.Mutex
+	var deltas []time.Duration
+	var maxLag time.Duration
+	var mismatches int
+	var wg sync.WaitGroup
+	for i := range clients {
+		wg.Add(1)
+		go func(client *etcd.Client) {
+			defer wg.Done()
+			for op := range ops {
+				var retries int
+				ctx := etcd.WithRetries(context.Background(), &retries)
+				start := time.Now()
+				_, err := etcd.Invoke(ctx, client, op.rec.Method, op.rec.Request)
+				latency := time.Since(start)
+				rep.Results() <- report.Result{TotalTime: latency, Retries: retries, Err: err}
+				bar.Increment()
+
+				mu.Lock()
+				deltas = append(deltas, latency-op.rec.Duration)
+				if !op.at.IsZero() {
+					maxLag = max(maxLag, start.Sub(op.at))
+				}
+				if (err == nil) != (op.rec.Err == nil) {
+					mismatches++
+				}
+				mu.Unlock()
+			}
+		}(clients[i])
+	}
+
+	go func() {
+		begin, origin := time.Now(), records[0].Start
+		for i := range records {
+			op := scheduled{rec: &records[i]}
+			if replaySpeed > 0 {
+				op.at = begin.Add(time.Duration(float64(records[i].Start.Sub(origin)) / replaySpeed))
+				time.Sleep(time.Until(op.at))
+			}
+			ops <- op
+		}
+		close(ops)
+	}()
+
+	rc := rep.Run()
+	wg.Wait()
+	close(rep.Results())
+	bar.Finish()
+
+	recorded := make([]time.Duration, 0, len(records))
+	for _, rec := range records {
+		recorded = append(recorded, rec.Duration)
+	}
+	slices.Sort(recorded)
+	slices.Sort(deltas)
+	stats := replayStats{
+		Stats:             <-rc,
+		Speed:             replaySpeed,
+		Recorded:          report.Percentiles(recorded),
+		Delta:             report.Percentiles(deltas),
+		MaxLag:            maxLag,
+		OutcomeMismatches: mismatches,
+	}
+	return printStats(stats)
+}
@@ -1,44 +1,364 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"log/slog"
+	"math/rand"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"google.golang.org/grpc/keepalive"
 
+	"github.com/ydb-platform/etcd-ydb/pkg/compactor"
 	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
+	"github.com/ydb-platform/etcd-ydb/pkg/report"
+	"github.com/ydb-platform/etcd-ydb/pkg/verify"
 )
 
 var RootCmd = &cobra.Command{
-	Use: "benchmark",
+	Use:                "benchmark",
+	PersistentPreRunE:  startCompactor,
+	PersistentPostRunE: stopCompactor,
 }
 
 var (
 	endpoints    []string
+	balancer     string
 	totalConns   uint
 	totalClients uint
+	maxRetries   uint
+	retryBackoff time.Duration
+
+	maxCallSendMsgSize int
+	maxCallRecvMsgSize int
+	keepaliveTime      time.Duration
+	keepaliveTimeout   time.Duration
+	compression        string
+	callTimeout        time.Duration
+	metricsAddr        string
+
+	autoCompactionMode      string
+	autoCompactionRetention string
+	autoCompactionInterval  time.Duration
+	autoCompactionPhysical  bool
+
+	faultSchedule string
+
+	loadScheduleSpec     string
+	loadScheduleInterval time.Duration
+
+	verifyResponses bool
 )
 
 func init() {
 	RootCmd.PersistentFlags().StringSliceVar(&endpoints, "endpoints", []string{"127.0.0.1:2379"}, "gRPC endpoints")
+	RootCmd.PersistentFlags().StringVar(&balancer, "balancer", etcd.RoundRobin, "Load balancing policy across endpoints (round_robin, pick_first)")
 	RootCmd.PersistentFlags().UintVar(&totalConns, "conns", 1, "Total number of gRPC connections")
 	RootCmd.PersistentFlags().UintVar(&totalClients, "clients", 1, "Total number of gRPC clients")
+	RootCmd.PersistentFlags().UintVar(&maxRetries, "max-retries", 0, "Maximum retries of idempotent requests on transient errors")
+	RootCmd.PersistentFlags().DurationVar(&retryBackoff, "retry-backoff", etcd.DefaultRetryPolicy.BaseBackoff, "Initial backoff between retries")
+	RootCmd.PersistentFlags().IntVar(&maxCallSendMsgSize, "max-send-bytes", 2*1024*1024, "Maximum size of a request message")
+	RootCmd.PersistentFlags().IntVar(&maxCallRecvMsgSize, "max-recv-bytes", 4*1024*1024, "Maximum size of a response message")
+	RootCmd.PersistentFlags().DurationVar(&keepaliveTime, "keepalive-time", 0, "Interval of client keepalive pings, 0 disables them")
+	RootCmd.PersistentFlags().DurationVar(&keepaliveTimeout, "keepalive-timeout", 20*time.Second, "Time to wait for a keepalive ping ack")
+	RootCmd.PersistentFlags().StringVar(&compression, "compression", "", "gRPC compressor of requests, e.g. gzip")
+	RootCmd.PersistentFlags().DurationVar(&callTimeout, "call-timeout", 0, "Timeout of a single request, 0 means no timeout")
+	RootCmd.PersistentFlags().StringVar(&metricsAddr, "metrics-addr", "", "Address to serve Prometheus client metrics on, e.g. :9100")
+	RootCmd.PersistentFlags().StringVar(&autoCompactionMode, "auto-compaction-mode", "", "Compact in the background during the benchmark (periodic, revision)")
+	RootCmd.PersistentFlags().StringVar(&autoCompactionRetention, "auto-compaction-retention", "1", "Retention of auto compaction: hours or a duration in periodic mode, revisions in revision mode")
+	RootCmd.PersistentFlags().DurationVar(&autoCompactionInterval, "auto-compaction-interval", 0, "Interval of auto compaction checks, 0 means the etcd default of the mode")
+	RootCmd.PersistentFlags().BoolVar(&autoCompactionPhysical, "auto-compaction-physical", false, "Wait for compacted revisions to be physically removed")
+	RootCmd.PersistentFlags().BoolVar(&verifyResponses, "verify", false, "Check responses for lost writes, stale reads and broken revisions and report anomalies")
+	RootCmd.PersistentFlags().StringVar(&loadScheduleSpec, "load-schedule", "", "Offer requests at rates following phases instead of the rate limits of workloads, e.g. ramp:100-1000+1m,step:1000-5000/4+2m,sine:500-2000/30s+5m,burst:200-2000/10s/1s+1m,const:500+1m")
+	RootCmd.PersistentFlags().DurationVar(&loadScheduleInterval, "load-schedule-interval", time.Second, "Interval of the series of offered and achieved load reported with a load schedule")
+	RootCmd.PersistentFlags().StringVar(&faultSchedule, "fault-schedule", "", "Send requests through a local proxy injecting faults on schedule, e.g. latency:50ms@10s+20s,unavailable*0.5@1m+10s,reset@2m")
+}
+
+var (
+	compactorClient *etcd.Client
+	compactorStop   context.CancelFunc
+	compactorDone   chan *compactor.Compactor
+)
+
+func startCompactor(_ *cobra.Command, _ []string) error {
+	if autoCompactionMode == "" {
+		return nil
+	}
+	client, err := etcd.NewClient(endpoints, etcd.WithBalancer(balancer), etcd.WithUserAgent("etcd-ydb-benchmark"))
+	if err != nil {
+		return err
+	}
+	c, err := compactor.New(client, compactor.Mode(autoCompactionMode), autoCompactionRetention,
+		compactor.WithInterval(autoCompactionInterval),
+		compactor.WithPhysical(autoCompactionPhysical),
+		compactor.WithLogger(slog.New(slog.NewTextHandler(os.Stderr, nil))),
+	)
+	if err != nil {
+		client.Close()
+		return err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	compactorClient, compactorStop, compactorDone = client, cancel, make(chan *compactor.Compactor, 1)
+	go func() {
+		c.Run(ctx)
+		compactorDone <- c
+	}()
+	return nil
 }
 
+func stopCompactor(_ *cobra.Command, _ []string) error {
+	if compactorStop == nil {
+		return nil
+	}
+	compactorStop()
+	c := <-compactorDone
+	fmt.Fprintf(os.Stderr, "%#v\n", c.Stats())
+	return compactorClient.Close()
+}
+
+// clients are shared by the workloads run in a single process.
+var clients []*etcd.Client
+
 func newClients() ([]*etcd.Client, error) {
-	if totalConns < uint(len(endpoints)) {
-		return nil, fmt.Errorf("conns < len(endpoints)")
+	if clients != nil {
+		return clients, nil
+	}
+	retryPolicy := etcd.DefaultRetryPolicy
+	retryPolicy.MaxRetries = maxRetries
+	retryPolicy.BaseBackoff = retryBackoff
+	opts := []etcd.Option{
+		etcd.WithBalancer(balancer),
+		etcd.WithRetryPolicy(retryPolicy),
+		etcd.WithMaxCallSendMsgSize(maxCallSendMsgSize),
+		etcd.WithMaxCallRecvMsgSize(maxCallRecvMsgSize),
+		etcd.WithCompression(compression),
+		etcd.WithCallTimeout(callTimeout),
+		etcd.WithUserAgent("etcd-ydb-benchmark"),
+	}
+	if keepaliveTime > 0 {
+		opts = append(opts, etcd.WithKeepalive(keepalive.ClientParameters{Time: keepaliveTime, Timeout: keepaliveTimeout}))
+	}
+	if metricsAddr != "" {
+		metrics := etcd.NewMetrics()
+		opts = append(opts, etcd.WithUnaryInterceptors(metrics.UnaryClientInterceptor()), etcd.WithStreamInterceptors(metrics.StreamClientInterceptor()))
+		go func() {
+			if err := http.ListenAndServe(metricsAddr, metrics); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		}()
+	}
+	if verifyResponses {
+		verifier = verify.New()
+	}
+	if err := startLoadSchedule(); err != nil {
+		return nil, err
+	}
+	targets := endpoints
+	if faultSchedule != "" {
+		addr, err := startFaultProxy(faultSchedule)
+		if err != nil {
+			return nil, err
+		}
+		targets = []string{addr}
 	}
 	conns := make([]*etcd.Client, totalConns)
 	for i := range conns {
-		conn, err := etcd.NewClient(endpoints[i%len(endpoints)])
+		conn, err := etcd.NewClient(targets, opts...)
 		if err != nil {
 			return nil, err
 		}
 		conns[i] = conn
 	}
-	clients := make([]*etcd.Client, totalClients)
-	for i := range clients {
-		clients[i] = conns[i%len(conns)]
+	shared := make([]*etcd.Client, totalClients)
+	for i := range shared {
+		shared[i] = conns[i%len(conns)]
 	}
+	clients = shared
 	return clients, nil
 }
+
+// Metadata describes the conditions a result was measured in.
+type Metadata struct {
+	Workload     string
+	Checkpoint   int
+	Keys         uint64
+	DatasetBytes uint64
+}
+
+// metadata is added to the printed results when set.
+var metadata *Metadata
+
+// printedStats are the last stats printed, for runWorkload to return.
+var printedStats any
+
+// printStats prints stats to stderr and as JSON to stdout, wrapped together
+// with the metadata when it is set.
+func printStats(stats any) error {
+	fmt.Fprintf(os.Stderr, "%#v\n", stats)
+	printed := stats
+	if metadata != nil {
+		printed = struct {
+			Stats    any
+			Metadata *Metadata
+		}{stats, metadata}
+	}
+	data, err := json.Marshal(printed)
+	if err != nil {
+		return err
+	}
+	printedStats = stats
+	fmt.Println(string(data))
+	return nil
+}
+
+// minUniqueValueSize is the least size of a value of uniqueValue unlikely to
+// be drawn twice.
+const minUniqueValueSize = 8
+
+// uniqueValue returns a random value of size bytes. A commit writing it can
+// tell its own write from those of others when the ack of its txn was lost
+// and the retry failed the compare.
+func uniqueValue(size uint64) string {
+	const digits = "0123456789abcdef"
+	value := make([]byte, size)
+	for i := range value {
+		value[i] = digits[rand.Intn(len(digits))]
+	}
+	return string(value)
+}
+
+// fillKey writes the n-th key of a key space into key, padded with '-'.
+func fillKey(key []byte, n uint64) {
+	j := 0
+	for ; n > 0; n /= 10 {
+		key[j] = byte('0' + n%10)
+		j++
+	}
+	slices.Reverse(key[:j])
+	for i := j; i < len(key); i++ {
+		key[i] = '-'
+	}
+}
+
+var (
+	verifier        *verify.Verifier
+	loggedAnomalies atomic.Int64
+)
+
+// maxLoggedAnomalies bounds the anomalies printed in full, the rest are only
+// counted in the report.
+const maxLoggedAnomalies = 10
+
+// newSession returns the verification session of a worker, or nil unless
+// responses are verified.
+func newSession() *verify.Session {
+	if verifier == nil {
+		return nil
+	}
+	return verifier.Session()
+}
+
+// do runs op on client and returns its result, with the anomalies found in
+// the response if session is not nil. Verification is not timed.
+func do(client *etcd.Client, session *verify.Session, op etcd.Request) report.Result {
+	var check *verify.Check
+	if session != nil {
+		check = session.Begin(op)
+	}
+	var retries int
+	ctx := etcd.WithRetries(context.Background(), &retries)
+	start := time.Now()
+	response, err := etcd.Do(ctx, client, op)
+	result := report.Result{TotalTime: time.Since(start), Retries: retries, Err: err, Deleted: deleted(response)}
+	if check == nil {
+		return result
+	}
+	for _, anomaly := range check.End(response, err) {
+		result.Anomalies = append(result.Anomalies, anomaly.Kind)
+		if loggedAnomalies.Add(1) <= maxLoggedAnomalies {
+			fmt.Fprintln(os.Stderr, anomaly)
+		}
+	}
+	return result
+}
+
+// deleted counts the keys deleted by the response of a request.
+func deleted(response etcd.Response) int64 {
+	switch r := response.(type) {
+	case *etcd.DeleteResponse:
+		return r.Deleted
+	case *etcd.TxnResponse:
+		var n int64
+		for _, op := range r.Responses {
+			n += deleted(op)
+		}
+		return n
+	}
+	return 0
+}
+
+// workloadDefaults are the defaults of the workload being run by runWorkload,
+// for the workloads it runs in turn.
+var workloadDefaults map[string]uint64
+
+// runWorkload runs a benchmark command line in this process on behalf of the
+// command caller and returns the stats it printed. Flags of the command that
+// are not set default to defaults rather than to their own defaults.
+func runWorkload(caller string, args []string, defaults map[string]uint64) (any, error) {
+	cmd, rest, err := RootCmd.Find(args)
+	if err != nil {
+		return nil, err
+	}
+	if cmd == RootCmd || cmd.RunE == nil {
+		return nil, fmt.Errorf("unknown workload")
+	}
+	// The flags of the caller would be reset, and load keeps global state.
+	if cmd.Name() == caller || cmd.Name() == "load" {
+		return nil, fmt.Errorf("%s cannot be nested in %s", cmd.Name(), caller)
+	}
+	// Commands keep their flags in globals, reset them from previous runs
+	// but keep the global ones.
+	cmd.LocalFlags().VisitAll(func(flag *pflag.Flag) {
+		if value, ok := flag.Value.(pflag.SliceValue); ok {
+			var items []string
+			if def := strings.Trim(flag.DefValue, "[]"); def != "" {
+				items = strings.Split(def, ",")
+			}
+			value.Replace(items)
+		} else {
+			flag.Value.Set(flag.DefValue)
+		}
+		flag.Changed = false
+	})
+	if err := cmd.Flags().Parse(rest); err != nil {
+		return nil, err
+	}
+	for name, value := range defaults {
+		if flag := cmd.Flags().Lookup(name); flag != nil && !flag.Changed {
+			if err := flag.Value.Set(strconv.FormatUint(value, 10)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	printedStats = nil
+	if schedule != nil {
+		// Every run follows the schedule from its start.
+		schedule.start = time.Time{}
+	}
+	saved := workloadDefaults
+	workloadDefaults = defaults
+	defer func() { workloadDefaults = saved }()
+	if err := cmd.RunE(cmd, cmd.Flags().Args()); err != nil {
+		return nil, err
+	}
+	return printedStats, nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+
+	"github.com/cheggaaa/pb/v3"
+	"github.com/spf13/cobra"
+	"go.etcd.io/etcd/api/v3/etcdserverpb"
+
+	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
+)
+
+var scanCmd = &cobra.Command{
+	Use:  "scan",
+	RunE: scanFunc,
+}
+
+var (
+	scanTotal             uint64
+	scanRateLimit         uint64
+	scanKeySize           uint64
+	scanKeySpaceSize      uint64
+	scanPrefixSize        uint64
+	scanLimit             int64
+	scanSortTarget        string
+	scanSortOrder         string
+	scanKeysOnly          bool
+	scanCountOnly         bool
+	scanRevisionLag       int64
+	scanMinModRevisionLag int64
+	scanMaxModRevisionLag int64
+)
+
+func init() {
+	RootCmd.AddCommand(scanCmd)
+	scanCmd.Flags().Uint64Var(&scanTotal, "total", 10000, "Total number of requests")
+	scanCmd.Flags().Uint64Var(&scanRateLimit, "rate-limit", math.MaxUint64, "Maximum requests per second")
+	scanCmd.Flags().Uint64Var(&scanKeySize, "key-size", 8, "Key size of request")
+	scanCmd.Flags().Uint64Var(&scanKeySpaceSize, "key-space-size", 1, "Maximum possible keys")
+	scanCmd.Flags().Uint64Var(&scanPrefixSize, "prefix-size", 0, "Size of the scanned prefix of a random key, 0 scans the whole key space")
+	scanCmd.Flags().Int64Var(&scanLimit, "limit", 0, "Maximum keys returned by a request, 0 means no limit")
+	scanCmd.Flags().StringVar(&scanSortTarget, "sort-target", "key", "Field to sort by (key, version, create, mod, value)")
+	scanCmd.Flags().StringVar(&scanSortOrder, "sort-order", "none", "Order of the sort (none, ascend, descend)")
+	scanCmd.Flags().BoolVar(&scanKeysOnly, "keys-only", false, "Return keys without values")
+	scanCmd.Flags().BoolVar(&scanCountOnly, "count-only", false, "Return the count of keys only")
+	scanCmd.Flags().Int64Var(&scanRevisionLag, "revision-lag", 0, "Read at this many revisions before the current one, 0 reads the latest")
+	scanCmd.Flags().Int64Var(&scanMinModRevisionLag, "min-mod-revision-lag", 0, "Only return keys modified within this many revisions before the current one, 0 disables the filter")
+	scanCmd.Flags().Int64Var(&scanMaxModRevisionLag, "max-mod-revision-lag", 0, "Only return keys modified at least this many revisions before the current one, 0 disables the filter")
+}
+
+// scanTemplate returns the request the scans are made of, with revisions
+// relative to the current one of the store.
+func scanTemplate(ctx context.Context, kv etcd.KV) (*etcd.RangeRequest, error) {
+	target, ok := etcdserverpb.RangeRequest_SortTarget_value[strings.ToUpper(scanSortTarget)]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort target %q", scanSortTarget)
+	}
+	order, ok := etcdserverpb.RangeRequest_SortOrder_value[strings.ToUpper(scanSortOrder)]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort order %q", scanSortOrder)
+	}
+	if scanPrefixSize > scanKeySize || scanLimit < 0 || scanRevisionLag < 0 || scanMinModRevisionLag < 0 || scanMaxModRevisionLag < 0 {
+		return nil, fmt.Errorf("invalid prefix size, limit or revision lags")
+	}
+	template := &etcd.RangeRequest{
+		Limit:      scanLimit,
+		SortTarget: etcdserverpb.RangeRequest_SortTarget(target),
+		SortOrder:  etcdserverpb.RangeRequest_SortOrder(order),
+		KeysOnly:   scanKeysOnly,
+		CountOnly:  scanCountOnly,
+	}
+	if scanRevisionLag == 0 && scanMinModRevisionLag == 0 && scanMaxModRevisionLag == 0 {
+		return template, nil
+	}
+	current, err := etcd.Range(ctx, kv, &etcd.RangeRequest{Key: etcd.EmptyKey, Limit: 1, CountOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	relative := func(lag int64) int64 {
+		if lag == 0 {
+			return 0
+		}
+		return max(current.Revision-lag, 1)
+	}
+	template.Revision = relative(scanRevisionLag)
+	template.MinModRevision = relative(scanMinModRevisionLag)
+	template.MaxModRevision = relative(scanMaxModRevisionLag)
+	return template, nil
+}
+
+func scanFunc(_ *cobra.Command, _ []string) error {
+	clients, err := newClients()
+	if err != nil {
+		return err
+	}
+	template, err := scanTemplate(context.Background(), clients[0])
+	if err != nil {
+		return err
+	}
+	limit := newLimiter(scanRateLimit)
+
+	bar := pb.New64(int64(scanTotal))
+	bar.Start()
+
+	ops := make(chan etcd.Request, totalClients)
+	rep := newReport()
+	var wg sync.WaitGroup
+	for i := range clients {
+		wg.Add(1)
+		go func(client *etcd.Client) {
+			defer wg.Done()
+			session := newSession()
+			for op := range ops {
+				limit.Wait(context.Background())
+				rep.Results() <- do(client, session, op)
+				bar.Increment()
+			}
+		}(clients[i])
+	}
+
+	go func() {
+		key := []byte(strings.Repeat("-", int(scanKeySize)))
+		for range scanTotal {
+			op := *template
+			if scanPrefixSize == 0 {
+				op.Key, op.RangeEnd = etcd.EmptyKey, etcd.EmptyKey
+			} else {
+				fillKey(key, rand.Uint64()%scanKeySpaceSize)
+				op.Key = string(key[:scanPrefixSize])
+				op.RangeEnd = etcd.GetPrefix(op.Key)
+			}
+			ops <- &op
+		}
+		close(ops)
+	}()
+
+	rc := rep.Run()
+	wg.Wait()
+	close(rep.Results())
+	bar.Finish()
+	stats := <-rc
+	return printStats(stats)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/ydb-platform/etcd-ydb/pkg/report"
+)
+
+// Kinds of load schedule phases.
+const (
+	phaseConst = "const"
+	phaseRamp  = "ramp"
+	phaseStep  = "step"
+	phaseSine  = "sine"
+	phaseBurst = "burst"
+)
+
+// phase offers requests at a rate varying from one rate to another over its
+// duration, by its kind: ramp linearly, step in steps equal steps, sine
+// between them with period and burst at the second one for width every
+// period.
+type phase struct {
+	kind     string
+	from, to float64
+	steps    int
+	period   time.Duration
+	width    time.Duration
+	duration time.Duration
+}
+
+// rate returns the requests per second offered at elapsed into the phase.
+func (p phase) rate(elapsed time.Duration) float64 {
+	progress := min(float64(elapsed)/float64(p.duration), 1)
+	switch p.kind {
+	case phaseRamp:
+		return p.from + (p.to-p.from)*progress
+	case phaseStep:
+		if p.steps < 2 {
+			return p.from
+		}
+		step := min(int(progress*float64(p.steps)), p.steps-1)
+		return p.from + (p.to-p.from)*float64(step)/float64(p.steps-1)
+	case phaseSine:
+		return p.from + (p.to-p.from)*(1-math.Cos(2*math.Pi*float64(elapsed)/float64(p.period)))/2
+	case phaseBurst:
+		if elapsed%p.period < p.width {
+			return p.to
+		}
+		return p.from
+	}
+	return p.from
+}
+
+// loadSchedule is a sequence of phases, the last rate holds after them.
+type loadSchedule struct {
+	phases []phase
+	start  time.Time
+}
+
+// parseLoadSchedule parses phases of the form kind:rates[/period[/width]]+duration
+// separated by commas, e.g. ramp:100-1000+1m,sine:500-2000/30s+5m.
+func parseLoadSchedule(s string) ([]phase, error) {
+	var phases []phase
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		spec, duration, ok := strings.Cut(field, "+")
+		if !ok {
+			return nil, fmt.Errorf("load schedule phase %q: missing +duration", field)
+		}
+		kind, args, _ := strings.Cut(spec, ":")
+		params := strings.Split(args, "/")
+		p := phase{kind: kind}
+		var err error
+		if p.duration, err = time.ParseDuration(duration); err != nil || p.duration <= 0 {
+			return nil, fmt.Errorf("load schedule phase %q: invalid duration", field)
+		}
+		from, to, ranged := strings.Cut(params[0], "-")
+		if p.from, err = strconv.ParseFloat(from, 64); err != nil || p.from <= 0 {
+			return nil, fmt.Errorf("load schedule phase %q: invalid rate", field)
+		}
+		p.to = p.from
+		if ranged {
+			if p.to, err = strconv.ParseFloat(to, 64); err != nil || p.to <= 0 {
+				return nil, fmt.Errorf("load schedule phase %q: invalid rate", field)
+			}
+		}
+
+		want := map[string]int{phaseConst: 1, phaseRamp: 1, phaseStep: 2, phaseSine: 2, phaseBurst: 3}[kind]
+		if want == 0 {
+			return nil, fmt.Errorf("load schedule phase %q: unknown kind", field)
+		}
+		if len(params) != want || (kind == phaseConst && ranged) || (kind != phaseConst && !ranged) {
+			return nil, fmt.Errorf("load schedule phase %q: invalid parameters", field)
+		}
+		switch kind {
+		case phaseStep:
+			if p.steps, err = strconv.Atoi(params[1]); err != nil || p.steps < 1 {
+				return nil, fmt.Errorf("load schedule phase %q: invalid steps", field)
+			}
+		case phaseSine, phaseBurst:
+			if p.period, err = time.ParseDuration(params[1]); err != nil || p.period <= 0 {
+				return nil, fmt.Errorf("load schedule phase %q: invalid period", field)
+			}
+			if kind == phaseBurst {
+				if p.width, err = time.ParseDuration(params[2]); err != nil || p.width <= 0 || p.width > p.period {
+					return nil, fmt.Errorf("load schedule phase %q: invalid width", field)
+				}
+			}
+		}
+		phases = append(phases, p)
+	}
+	return phases, nil
+}
+
+// rate returns the requests per second offered at t.
+func (s *loadSchedule) rate(t time.Time) float64 {
+	elapsed := t.Sub(s.start)
+	for _, p := range s.phases {
+		if elapsed < p.duration {
+			return p.rate(max(elapsed, 0))
+		}
+		elapsed -= p.duration
+	}
+	last := s.phases[len(s.phases)-1]
+	return last.rate(last.duration)
+}
+
+// limiter paces the requests of a workload.
+type limiter interface {
+	Wait(ctx context.Context) error
+}
+
+// scheduledLimiter follows the load schedule, whose clock starts with every
+// workload run.
+type scheduledLimiter struct {
+	*rate.Limiter
+	schedule *loadSchedule
+}
+
+func (l scheduledLimiter) Wait(ctx context.Context) error {
+	l.SetLimit(rate.Limit(l.schedule.rate(time.Now())))
+	return l.Limiter.Wait(ctx)
+}
+
+// schedule is set when the load follows a schedule rather than the rate
+// limits of the workloads.
+var schedule *loadSchedule
+
+func startLoadSchedule() error {
+	if loadScheduleSpec == "" || schedule != nil {
+		return nil
+	}
+	phases, err := parseLoadSchedule(loadScheduleSpec)
+	if err != nil {
+		return err
+	}
+	schedule = &loadSchedule{phases: phases}
+	return nil
+}
+
+// startClock starts the clock of the load schedule unless it runs already, so
+// that the setup of a workload does not count against its phases. runWorkload
+// stops it before every run.
+func (s *loadSchedule) startClock() {
+	if s.start.IsZero() {
+		s.start = time.Now()
+	}
+}
+
+// newLimiter returns the limiter of a workload with the given rate limit, or
+// one following the load schedule if set.
+func newLimiter(limit uint64) limiter {
+	if schedule == nil {
+		return rate.NewLimiter(rate.Limit(limit), 1)
+	}
+	schedule.startClock()
+	return scheduledLimiter{Limiter: rate.NewLimiter(rate.Limit(schedule.rate(time.Now())), 1), schedule: schedule}
+}
+
+// newReport returns a report of a workload, with the series of offered and
+// achieved load if it follows the load schedule.
+func newReport() report.Report {
+	if schedule == nil {
+		return report.NewReport(totalClients)
+	}
+	schedule.startClock()
+	return report.NewReport(totalClients, report.WithSeries(loadScheduleInterval, schedule.rate))
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/ydb-platform/etcd-ydb/pkg/report"
+)
+
+var sloCmd = &cobra.Command{
+	Use:  "slo",
+	RunE: sloFunc,
+}
+
+var (
+	sloWorkload      string
+	sloPercentile    float64
+	sloLatency       time.Duration
+	sloMaxErrorRate  float64
+	sloMinThroughput float64
+	sloStartRate     uint64
+	sloMaxRate       uint64
+	sloStepFactor    float64
+	sloSearch        string
+	sloPrecision     float64
+)
+
+func init() {
+	RootCmd.AddCommand(sloCmd)
+	sloCmd.Flags().StringVar(&sloWorkload, "workload", "put", "Workload run at every offered rate through its --rate-limit, e.g. \"txn-put --txn-ops=8 --total=20000\"")
+	sloCmd.Flags().Float64Var(&sloPercentile, "percentile", 99, "Latency percentile of the SLO")
+	sloCmd.Flags().DurationVar(&sloLatency, "latency", 50*time.Millisecond, "Maximum latency at the percentile")
+	sloCmd.Flags().Float64Var(&sloMaxErrorRate, "max-error-rate", 0.01, "Maximum share of failed requests")
+	sloCmd.Flags().Float64Var(&sloMinThroughput, "min-throughput", 0.9, "Minimum share of the offered rate the target must sustain")
+	sloCmd.Flags().Uint64Var(&sloStartRate, "start-rate", 100, "Offered requests per second of the first step")
+	sloCmd.Flags().Uint64Var(&sloMaxRate, "max-rate", 1_000_000, "Maximum offered requests per second")
+	sloCmd.Flags().Float64Var(&sloStepFactor, "step-factor", 2, "Factor of the offered rate between steps")
+	sloCmd.Flags().StringVar(&sloSearch, "search", "binary", "Search between the last step within the SLO and the first one violating it (binary, none)")
+	sloCmd.Flags().Float64Var(&sloPrecision, "precision", 0.05, "Relative precision the binary search stops at")
+}
+
+// sloStep is the outcome of the workload at an offered rate.
+type sloStep struct {
+	Rate        uint64
+	RPS         float64
+	Latency     time.Duration
+	ErrorRate   float64
+	Passed      bool
+	Percentiles []report.Percentile
+}
+
+type sloStats struct {
+	Workload   string
+	Percentile float64
+	Latency    time.Duration
+	// MaxRate is the highest offered rate within the SLO and MaxRPS the
+	// throughput achieved at it.
+	MaxRate uint64
+	MaxRPS  float64
+	Steps   []sloStep
+}
+
+// sloRun runs the workload at the offered rate and checks it against the SLO.
+func sloRun(rate uint64) (sloStep, error) {
+	args := append(strings.Fields(sloWorkload), "--rate-limit="+strconv.FormatUint(rate, 10))
+	// Steps keep the metadata of a load running the search.
+	saved, step := metadata, &Metadata{}
+	if saved != nil {
+		*step = *saved
+	}
+	step.Workload = strings.Join(args, " ")
+	metadata = step
+	// The workload keeps the defaults of a load running the search.
+	printed, err := runWorkload("slo", args, workloadDefaults)
+	metadata = saved
+	if err != nil {
+		return sloStep{}, err
+	}
+	stats, ok := flatStats(printed)
+	if !ok {
+		return sloStep{}, fmt.Errorf("workload %q does not report the stats of a single kind of request", sloWorkload)
+	}
+
+	result := sloStep{Rate: rate, RPS: stats.RPS, Percentiles: stats.Percentiles, Latency: -1}
+	for _, percentile := range stats.Percentiles {
+		if percentile.Percentile == sloPercentile {
+			result.Latency = percentile.Latency
+		}
+	}
+	var errors int
+	for _, n := range stats.Errors {
+		errors += n
+	}
+	if total := stats.Total + errors; total > 0 {
+		result.ErrorRate = float64(errors) / float64(total)
+	}
+	result.Passed = stats.Total > 0 && result.Latency >= 0 && result.Latency <= sloLatency &&
+		result.ErrorRate <= sloMaxErrorRate && stats.RPS >= sloMinThroughput*float64(rate)
+	return result, nil
+}
+
+func sloFunc(_ *cobra.Command, _ []string) error {
+	if sloStartRate < 1 || sloMaxRate < sloStartRate || sloStepFactor <= 1 || sloPrecision <= 0 {
+		return fmt.Errorf("invalid start rate %d, max rate %d, step factor %v or precision %v", sloStartRate, sloMaxRate, sloStepFactor, sloPrecision)
+	}
+	if sloSearch != "binary" && sloSearch != "none" {
+		return fmt.Errorf("unknown search %q", sloSearch)
+	}
+	if strings.Contains(sloWorkload, "--rate-limit") {
+		return fmt.Errorf("the workload rate is set by the search")
+	}
+	if loadScheduleSpec != "" {
+		return fmt.Errorf("the search cannot follow a load schedule")
+	}
+	if !isReportPercentile(sloPercentile) {
+		return fmt.Errorf("percentile %v is not reported", sloPercentile)
+	}
+
+	stats := sloStats{Workload: sloWorkload, Percentile: sloPercentile, Latency: sloLatency}
+	run := func(rate uint64) (bool, error) {
+		step, err := sloRun(rate)
+		if err != nil {
+			return false, err
+		}
+		stats.Steps = append(stats.Steps, step)
+		if step.Passed && rate > stats.MaxRate {
+			stats.MaxRate, stats.MaxRPS = rate, step.RPS
+		}
+		return step.Passed, nil
+	}
+
+	// Step up the offered rate until the SLO is violated.
+	var failed uint64
+	for rate := sloStartRate; ; {
+		passed, err := run(rate)
+		if err != nil {
+			return err
+		}
+		if !passed {
+			failed = rate
+			break
+		}
+		if rate >= sloMaxRate {
+			break
+		}
+		// The truncated product may not advance a low rate, and may not fit
+		// near the maximum one.
+		if next := float64(rate) * sloStepFactor; next < float64(sloMaxRate) {
+			rate = max(rate+1, uint64(next))
+		} else {
+			rate = sloMaxRate
+		}
+	}
+
+	// Then search between the last rate within the SLO and the failed one.
+	if sloSearch == "binary" && failed != 0 && stats.MaxRate != 0 {
+		lo, hi := stats.MaxRate, failed
+		for hi-lo > 1 && float64(hi) > float64(lo)*(1+sloPrecision) {
+			mid := lo + (hi-lo)/2
+			passed, err := run(mid)
+			if err != nil {
+				return err
+			}
+			if passed {
+				lo = mid
+			} else {
+				hi = mid
+			}
+		}
+	}
+	return printStats(stats)
+}
+
+// flatStats returns the stats of a workload reporting a single kind of
+// request, as report.Stats or a struct embedding them.
+func flatStats(stats any) (report.Stats, bool) {
+	if s, ok := stats.(report.Stats); ok {
+		return s, true
+	}
+	v := reflect.ValueOf(stats)
+	if v.Kind() != reflect.Struct {
+		return report.Stats{}, false
+	}
+	field, ok := v.Type().FieldByName("Stats")
+	if !ok || !field.Anonymous || field.Type != reflect.TypeOf(report.Stats{}) {
+		return report.Stats{}, false
+	}
+	return v.FieldByIndex(field.Index).Interface().(report.Stats), true
+}
+
+func isReportPercentile(percentile float64) bool {
+	for _, p := range report.Percentiles([]time.Duration{0}) {
+		if p.Percentile == percentile {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/cheggaaa/pb/v3"
+	"github.com/spf13/cobra"
+
+	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
+	"github.com/ydb-platform/etcd-ydb/pkg/report"
+)
+
+var watchCmd = &cobra.Command{
+	Use:  "watch",
+	RunE: watchFunc,
+}
+
+var (
+	watchTotal           uint64
+	watchRateLimit       uint64
+	watchKeySize         uint64
+	watchValSize         uint64
+	watchKeySpaceSize    uint64
+	watchWatchers        int
+	watchPrefixes        int
+	watchCatchUpWatchers int
+	watchDrainTimeout    time.Duration
+)
+
+func init() {
+	RootCmd.AddCommand(watchCmd)
+	watchCmd.Flags().Uint64Var(&watchTotal, "total", 10000, "Total number of puts")
+	watchCmd.Flags().Uint64Var(&watchRateLimit, "rate-limit", math.MaxUint64, "Maximum puts per second")
+	watchCmd.Flags().Uint64Var(&watchKeySize, "key-size", 8, "Key size of request")
+	watchCmd.Flags().Uint64Var(&watchValSize, "val-size", 8, "Value size of request")
+	watchCmd.Flags().Uint64Var(&watchKeySpaceSize, "key-space-size", 1, "Maximum possible keys under a prefix")
+	watchCmd.Flags().IntVar(&watchWatchers, "watchers", 10, "Number of watchers, spread over the prefixes")
+	watchCmd.Flags().IntVar(&watchPrefixes, "prefixes", 1, "Number of watched prefixes the puts are spread over")
+	watchCmd.Flags().IntVar(&watchCatchUpWatchers, "catch-up-watchers", 1, "Number of watchers started from the revision before the puts once they are done")
+	watchCmd.Flags().DurationVar(&watchDrainTimeout, "drain-timeout", 10*time.Second, "Time to wait for the watchers to receive all events after the puts")
+}
+
+type watchStats struct {
+	// Stats are those of the puts.
+	report.Stats
+	Watchers int
+	Prefixes int
+	// Events counts the events delivered to all watchers and Missed those
+	// not delivered within the drain timeout or before the stream failed,
+	// Failed the watchers whose stream failed, FanOut the events per put.
+	// Latency is the time from the ack of a put to the receipt of its event.
+	Events          int64
+	Missed          int64
+	Failed          int
+	FanOut          float64
+	EventsPerSecond float64
+	Latency         []report.Percentile
+	AverageLatency  time.Duration
+	// CatchUp are the percentiles of the time watchers started from the
+	// revision before the puts took to receive all of their events.
+	CatchUp       []report.Percentile
+	CatchUpEvents int64
+}
+
+// receipt is an event received by a watcher.
+type receipt struct {
+	revision int64
+	at       time.Time
+}
+
+type watcher struct {
+	prefix   int
+	receipts []receipt
+	// revision is the latest revision received.
+	revision atomic.Int64
+	// failed is set once the watch stream failed.
+	failed atomic.Bool
+}
+
+func (w *watcher) collect(ch <-chan *etcd.WatchResponse) {
+	for response := range ch {
+		if response.Err != nil {
+			w.failed.Store(true)
+			return
+		}
+		now := time.Now()
+		for _, event := range response.Events {
+			w.receipts = append(w.receipts, receipt{revision: event.Kv.ModRevision, at: now})
+			w.revision.Store(event.Kv.ModRevision)
+		}
+	}
+}
+
+func watchPrefix(i int) string {
+	return fmt.Sprintf("watch_%d/", i)
+}
+
+func watchFunc(_ *cobra.Command, _ []string) error {
+	if watchWatchers < 0 || watchPrefixes < 1 || watchCatchUpWatchers < 0 {
+		return fmt.Errorf("invalid watchers %d, prefixes %d or catch-up watchers %d", watchWatchers, watchPrefixes, watchCatchUpWatchers)
+	}
+	clients, err := newClients()
+	if err != nil {
+		return err
+	}
+	current, err := etcd.Range(context.Background(), clients[0], &etcd.RangeRequest{Key: etcd.EmptyKey, Limit: 1, CountOnly: true})
+	if err != nil {
+		return err
+	}
+	startRevision := current.Revision + 1
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watchers := make([]*watcher, watchWatchers)
+	var collectors sync.WaitGroup
+	for i := range watchers {
+		w := &watcher{prefix: i % watchPrefixes}
+		prefix := watchPrefix(w.prefix)
+		ch, err := etcd.Watch(ctx, clients[i%len(clients)], &etcd.WatchRequest{Key: prefix, RangeEnd: etcd.GetPrefix(prefix)})
+		if err != nil {
+			return err
+		}
+		watchers[i] = w
+		collectors.Add(1)
+		go func() {
+			defer collectors.Done()
+			w.collect(ch)
+		}()
+	}
+
+	limit := newLimiter(watchRateLimit)
+	bar := pb.New64(int64(watchTotal))
+	bar.Start()
+
+	type put struct {
+		prefix int
+		op     *etcd.PutRequest
+	}
+	ops := make(chan put, totalClients)
+	rep := newReport()
+	var mu sync.Mutex
+	acks := make(map[int64]time.Time)
+	writes := make([]int64, watchPrefixes)
+	lastRevisions := make([]int64, watchPrefixes)
+	var wg sync.WaitGroup
+	for i := range clients {
+		wg.Add(1)
+		go func(client *etcd.Client) {
+			defer wg.Done()
+			for p := range ops {
+				limit.Wait(context.Background())
+				var retries int
+				ctx := etcd.WithRetries(context.Background(), &retries)
+				start := time.Now()
+				response, err := etcd.Put(ctx, client, p.op)
+				now := time.Now()
+				rep.Results() <- report.Result{TotalTime: now.Sub(start), Retries: retries, Err: err}
+				bar.Increment()
+				if err != nil {
+					continue
+				}
+				mu.Lock()
+				acks[response.Revision] = now
+				writes[p.prefix]++
+				lastRevisions[p.prefix] = max(lastRevisions[p.prefix], response.Revision)
+				mu.Unlock()
+			}
+		}(clients[i])
+	}
+
+	go func() {
+		key, value := []byte(strings.Repeat("-", int(watchKeySize))), strings.Repeat("-", int(watchValSize))
+		for range watchTotal {
+			prefix := rand.Intn(watchPrefixes)
+			fillKey(key, rand.Uint64()%watchKeySpaceSize)
+			ops <- put{prefix: prefix, op: &etcd.PutRequest{Key: watchPrefix(prefix) + string(key), Value: value}}
+		}
+		close(ops)
+	}()
+
+	rc := rep.Run()
+	wg.Wait()
+	close(rep.Results())
+	bar.Finish()
+	stats := watchStats{Stats: <-rc, Watchers: watchWatchers, Prefixes: watchPrefixes}
+
+	// The watchers are done once they received the last put of their prefix.
+	deadline := time.Now().Add(watchDrainTimeout)
+	for _, w := range watchers {
+		for w.revision.Load() < lastRevisions[w.prefix] && !w.failed.Load() && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	cancel()
+	collectors.Wait()
+
+	var latencies []time.Duration
+	var first, last time.Time
+	for _, w := range watchers {
+		if w.failed.Load() {
+			stats.Failed++
+		}
+		stats.Events += int64(len(w.receipts))
+		stats.Missed += max(writes[w.prefix]-int64(len(w.receipts)), 0)
+		for _, r := range w.receipts {
+			if ack, ok := acks[r.revision]; ok {
+				// An event may arrive before the ack of its put.
+				latencies = append(latencies, max(r.at.Sub(ack), 0))
+			}
+			if first.IsZero() || r.at.Before(first) {
+				first = r.at
+			}
+			last = maxTime(last, r.at)
+		}
+	}
+	if stats.Total > 0 {
+		stats.FanOut = float64(stats.Events) / float64(stats.Total)
+	}
+	if elapsed := last.Sub(first); elapsed > 0 {
+		stats.EventsPerSecond = float64(stats.Events) / elapsed.Seconds()
+	}
+	if len(latencies) > 0 {
+		slices.Sort(latencies)
+		var total time.Duration
+		for _, latency := range latencies {
+			total += latency
+		}
+		stats.Latency = report.Percentiles(latencies)
+		stats.AverageLatency = total / time.Duration(len(latencies))
+	}
+
+	catchUp, events, err := watchCatchUp(clients, startRevision, lastRevisions)
+	if err != nil {
+		return err
+	}
+	slices.Sort(catchUp)
+	stats.CatchUp = report.Percentiles(catchUp)
+	stats.CatchUpEvents = events
+	return printStats(stats)
+}
+
+// watchCatchUp starts watchers from startRevision and returns the times they
+// took to receive the events up to the last revision of their prefix.
+func watchCatchUp(clients []*etcd.Client, startRevision int64, lastRevisions []int64) ([]time.Duration, int64, error) {
+	var durations []time.Duration
+	var events int64
+	for i := range watchCatchUpWatchers {
+		prefix := i % watchPrefixes
+		if lastRevisions[prefix] == 0 {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), watchDrainTimeout)
+		start := time.Now()
+		ch, err := etcd.Watch(ctx, clients[i%len(clients)], &etcd.WatchRequest{
+			Key:           watchPrefix(prefix),
+			RangeEnd:      etcd.GetPrefix(watchPrefix(prefix)),
+			StartRevision: startRevision,
+		})
+		if err != nil {
+			cancel()
+			return nil, 0, err
+		}
+		var revision int64
+		for response := range ch {
+			if response.Err != nil {
+				cancel()
+				return nil, 0, fmt.Errorf("catch-up watch of %s: %w", watchPrefix(prefix), response.Err)
+			}
+			if response.CompactRevision != 0 {
+				cancel()
+				return nil, 0, fmt.Errorf("catch-up watch from compacted revision %d", startRevision)
+			}
+			events += int64(len(response.Events))
+			if len(response.Events) != 0 {
+				revision = response.Events[len(response.Events)-1].Kv.ModRevision
+			}
+			if revision >= lastRevisions[prefix] {
+				break
+			}
+		}
+		cancel()
+		if revision < lastRevisions[prefix] {
+			return nil, 0, fmt.Errorf("catch-up watch of %s did not reach revision %d", watchPrefix(prefix), lastRevisions[prefix])
+		}
+		durations = append(durations, time.Since(start))
+	}
+	return durations, events, nil
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,404 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/cheggaaa/pb/v3"
+	"github.com/spf13/cobra"
+	"golang.org/x/time/rate"
+
+	"github.com/ydb-platform/etcd-ydb/pkg/etcd"
+	"github.com/ydb-platform/etcd-ydb/pkg/report"
+)
+
+var ycsbCmd = &cobra.Command{
+	Use:  "ycsb",
+	RunE: ycsbFunc,
+}
+
+var (
+	ycsbWorkload        string
+	ycsbRecordCount     uint64
+	ycsbOperationCount  uint64
+	ycsbFieldCount      int
+	ycsbFieldLength     int
+	ycsbWriteAllFields  bool
+	ycsbMaxScanLength   int64
+	ycsbZipfianConstant float64
+	ycsbRequestDist     string
+	ycsbLoad            bool
+	ycsbRateLimit       uint64
+)
+
+func init() {
+	RootCmd.AddCommand(ycsbCmd)
+	ycsbCmd.Flags().StringVar(&ycsbWorkload, "workload", "a", "YCSB core workload (a, b, c, d, e, f)")
+	ycsbCmd.Flags().Uint64Var(&ycsbRecordCount, "record-count", 1000, "Number of records loaded before the run")
+	ycsbCmd.Flags().Uint64Var(&ycsbOperationCount, "operation-count", 1000, "Number of operations of the run")
+	ycsbCmd.Flags().IntVar(&ycsbFieldCount, "field-count", 10, "Number of fields of a record, each stored under its own key")
+	ycsbCmd.Flags().IntVar(&ycsbFieldLength, "field-length", 100, "Length of a field")
+	ycsbCmd.Flags().BoolVar(&ycsbWriteAllFields, "write-all-fields", false, "Write all fields of a record on updates instead of a random one")
+	ycsbCmd.Flags().Int64Var(&ycsbMaxScanLength, "max-scan-length", 100, "Maximum records of a scan, the lengths are uniform")
+	ycsbCmd.Flags().Float64Var(&ycsbZipfianConstant, "zipfian-constant", 0.99, "Skew of the zipfian distributions")
+	ycsbCmd.Flags().StringVar(&ycsbRequestDist, "request-distribution", "", "Distribution of requested records (zipfian, uniform, latest), defaults to the one of the workload")
+	ycsbCmd.Flags().BoolVar(&ycsbLoad, "load", true, "Insert the records before the run")
+	ycsbCmd.Flags().Uint64Var(&ycsbRateLimit, "rate-limit", math.MaxUint64, "Maximum operations per second")
+}
+
+// Operations of YCSB workloads.
+const (
+	ycsbRead            = "READ"
+	ycsbUpdate          = "UPDATE"
+	ycsbInsert          = "INSERT"
+	ycsbScan            = "SCAN"
+	ycsbReadModifyWrite = "READ-MODIFY-WRITE"
+)
+
+// ycsbCoreWorkload is a core workload as defined by the workload files of
+// YCSB.
+type ycsbCoreWorkload struct {
+	proportions  map[string]float64
+	distribution string
+}
+
+var ycsbWorkloads = map[string]ycsbCoreWorkload{
+	"a": {map[string]float64{ycsbRead: 0.5, ycsbUpdate: 0.5}, "zipfian"},
+	"b": {map[string]float64{ycsbRead: 0.95, ycsbUpdate: 0.05}, "zipfian"},
+	"c": {map[string]float64{ycsbRead: 1}, "zipfian"},
+	"d": {map[string]float64{ycsbRead: 0.95, ycsbInsert: 0.05}, "latest"},
+	"e": {map[string]float64{ycsbScan: 0.95, ycsbInsert: 0.05}, "zipfian"},
+	"f": {map[string]float64{ycsbRead: 0.5, ycsbReadModifyWrite: 0.5}, "zipfian"},
+}
+
+// ycsbOperations orders the operations for the choice by proportion.
+var ycsbOperations = []string{ycsbRead, ycsbUpdate, ycsbInsert, ycsbScan, ycsbReadModifyWrite}
+
+const ycsbPrefix = "user"
+
+// ycsbKey returns the key of the n-th record, hashed to spread the inserts
+// over the key space as YCSB does.
+func ycsbKey(n uint64) string {
+	return ycsbPrefix + strconv.FormatUint(fnvHash64(n), 10)
+}
+
+// fnvHash64 is the FNV-1a hash of the bytes of n used by YCSB.
+func fnvHash64(n uint64) uint64 {
+	hash := int64(-3750763034362895579) // 0xCBF29CE484222325
+	for range 8 {
+		hash ^= int64(n & 0xff)
+		hash *= 1099511628211
+		n >>= 8
+	}
+	if hash < 0 {
+		hash = -hash
+	}
+	return uint64(hash)
+}
+
+// ycsbFields returns the prefix of the keys of the fields of the record at
+// key. Every field is stored under its own key, so that an update of a field
+// leaves the others of the record as they are.
+func ycsbFields(key string) string {
+	return key + "/"
+}
+
+// ycsbWrites returns the puts of the fields written by an operation on the
+// record at key: all of them for inserts and, as YCSB does by default, a
+// random one for updates.
+func ycsbWrites(key, operation string) []etcd.Request {
+	first, last := 0, ycsbFieldCount
+	if operation != ycsbInsert && !ycsbWriteAllFields {
+		first = rand.Intn(ycsbFieldCount)
+		last = first + 1
+	}
+	writes := make([]etcd.Request, 0, last-first)
+	for i := first; i < last; i++ {
+		value := make([]byte, ycsbFieldLength)
+		for j := range value {
+			value[j] = byte(' ' + rand.Intn(95))
+		}
+		writes = append(writes, &etcd.PutRequest{Key: ycsbFields(key) + "field" + strconv.Itoa(i), Value: string(value)})
+	}
+	return writes
+}
+
+// zipfian draws items from [0, n) with the zipfian distribution of YCSB, 0
+// being the most popular. The number of items may grow between draws.
+type zipfian struct {
+	theta, alpha, zeta2, zetan, eta float64
+	n                               uint64
+}
+
+func newZipfian(n uint64, theta float64) *zipfian {
+	z := &zipfian{theta: theta, alpha: 1 / (1 - theta), zeta2: zeta(0, 2, theta, 0)}
+	z.grow(n)
+	return z
+}
+
+// zeta adds the terms of the items from to n to the sum of the first ones.
+func zeta(from, n uint64, theta, sum float64) float64 {
+	for i := from; i < n; i++ {
+		sum += 1 / math.Pow(float64(i+1), theta)
+	}
+	return sum
+}
+
+func (z *zipfian) grow(n uint64) {
+	if n <= z.n {
+		return
+	}
+	z.zetan = zeta(z.n, n, z.theta, z.zetan)
+	z.n = n
+	z.eta = (1 - math.Pow(2/float64(n), 1-z.theta)) / (1 - z.zeta2/z.zetan)
+}
+
+func (z *zipfian) next() uint64 {
+	u := rand.Float64()
+	uz := u * z.zetan
+	if uz < 1 {
+		return 0
+	}
+	if uz < 1+math.Pow(0.5, z.theta) {
+		return 1
+	}
+	return min(uint64(float64(z.n)*math.Pow(z.eta*u-z.eta+1, z.alpha)), z.n-1)
+}
+
+// ycsbAcknowledged counts the records whose inserts are done without a gap,
+// as the acknowledged counter of YCSB, so that no operation requests a record
+// still being inserted.
+type ycsbAcknowledged struct {
+	mu      sync.Mutex
+	count   uint64
+	pending map[uint64]bool
+}
+
+// acknowledge marks the insert of the n-th record done.
+func (a *ycsbAcknowledged) acknowledge(n uint64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if n != a.count {
+		a.pending[n] = true
+		return
+	}
+	for a.count++; a.pending[a.count]; a.count++ {
+		delete(a.pending, a.count)
+	}
+}
+
+func (a *ycsbAcknowledged) load() uint64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.count
+}
+
+// ycsbChooser draws the records requested by the operations of a workload.
+type ycsbChooser struct {
+	distribution string
+	zipfian      *zipfian
+	// inserted counts the records loaded or inserted, acknowledged those
+	// done.
+	inserted     uint64
+	acknowledged *ycsbAcknowledged
+}
+
+func (c *ycsbChooser) next() uint64 {
+	records := c.acknowledged.load()
+	switch c.distribution {
+	case "uniform":
+		return rand.Uint64() % records
+	case "latest":
+		c.zipfian.grow(records)
+		return records - 1 - c.zipfian.next()
+	default:
+		// The popular records are scattered over the key space.
+		return fnvHash64(c.zipfian.next()) % records
+	}
+}
+
+type ycsbOp struct {
+	operation string
+	key       string
+	writes    []etcd.Request
+	length    int64
+	// done is called once an insert is done.
+	done func()
+}
+
+type ycsbStats struct {
+	Workload     string
+	Distribution string
+	Load         report.Stats
+	// Operations are the stats of the run by operation.
+	Operations map[string]report.Stats
+	// Series is the load of the run over time if it follows the load
+	// schedule.
+	Series []report.Sample `json:",omitempty"`
+}
+
+// ycsbDo runs an operation of the run on client.
+func ycsbDo(ctx context.Context, client *etcd.Client, op ycsbOp) error {
+	switch op.operation {
+	case ycsbRead:
+		_, err := etcd.Range(ctx, client, &etcd.RangeRequest{Key: ycsbFields(op.key), RangeEnd: etcd.GetPrefix(ycsbFields(op.key))})
+		return err
+	case ycsbScan:
+		// The fields of the records of a scan are in a row.
+		_, err := etcd.Range(ctx, client, &etcd.RangeRequest{Key: ycsbFields(op.key), RangeEnd: etcd.GetPrefix(ycsbPrefix), Limit: op.length * int64(ycsbFieldCount)})
+		return err
+	case ycsbReadModifyWrite:
+		if _, err := etcd.Range(ctx, client, &etcd.RangeRequest{Key: ycsbFields(op.key), RangeEnd: etcd.GetPrefix(ycsbFields(op.key))}); err != nil {
+			return err
+		}
+	}
+	if len(op.writes) == 1 {
+		_, err := etcd.Put(ctx, client, op.writes[0].(*etcd.PutRequest))
+		return err
+	}
+	_, err := etcd.Txn(ctx, client, &etcd.TxnRequest{Success: op.writes})
+	return err
+}
+
+// ycsbRun runs the ops sent by generate on all clients at the pace of limit
+// and returns the stats of every operation and those of all of them, reported
+// by all.
+func ycsbRun(clients []*etcd.Client, total uint64, limit limiter, all report.Report, generate func(ops chan<- ycsbOp)) (map[string]report.Stats, report.Stats) {
+	bar := pb.New64(int64(total))
+	bar.Start()
+
+	reports := make(map[string]report.Report)
+	rcs := make(map[string]<-chan report.Stats)
+	for _, operation := range ycsbOperations {
+		reports[operation] = report.NewReport(totalClients)
+		rcs[operation] = reports[operation].Run()
+	}
+	allc := all.Run()
+	ops := make(chan ycsbOp, totalClients)
+	var wg sync.WaitGroup
+	for i := range clients {
+		wg.Add(1)
+		go func(client *etcd.Client) {
+			defer wg.Done()
+			for op := range ops {
+				limit.Wait(context.Background())
+				switch op.operation {
+				case ycsbInsert, ycsbUpdate, ycsbReadModifyWrite:
+					op.writes = ycsbWrites(op.key, op.operation)
+				}
+				result := timed(func(ctx context.Context) error { return ycsbDo(ctx, client, op) })
+				reports[op.operation].Results() <- result
+				all.Results() <- result
+				if op.done != nil {
+					op.done()
+				}
+				bar.Increment()
+			}
+		}(clients[i])
+	}
+
+	go func() {
+		generate(ops)
+		close(ops)
+	}()
+
+	wg.Wait()
+	stats := make(map[string]report.Stats)
+	for operation, rep := range reports {
+		close(rep.Results())
+		if s := <-rcs[operation]; s.Total > 0 || len(s.Errors) > 0 {
+			stats[operation] = s
+		}
+	}
+	close(all.Results())
+	bar.Finish()
+	return stats, <-allc
+}
+
+func ycsbFunc(_ *cobra.Command, _ []string) error {
+	workload, ok := ycsbWorkloads[strings.ToLower(ycsbWorkload)]
+	if !ok {
+		return fmt.Errorf("unknown workload %q", ycsbWorkload)
+	}
+	if ycsbRequestDist != "" {
+		workload.distribution = ycsbRequestDist
+	}
+	switch workload.distribution {
+	case "zipfian", "uniform", "latest":
+	default:
+		return fmt.Errorf("unknown request distribution %q", workload.distribution)
+	}
+	if ycsbRecordCount < 1 || ycsbMaxScanLength < 1 || ycsbZipfianConstant <= 0 || ycsbZipfianConstant >= 1 {
+		return fmt.Errorf("invalid record count %d, max scan length %d or zipfian constant %v", ycsbRecordCount, ycsbMaxScanLength, ycsbZipfianConstant)
+	}
+	// The fields of a record are written by a single txn and read by scans
+	// of up to max-scan-length records.
+	if ycsbFieldCount < 1 || ycsbFieldCount > etcd.MaxTxnOps || ycsbMaxScanLength > math.MaxInt64/int64(ycsbFieldCount) || ycsbFieldLength < 0 {
+		return fmt.Errorf("invalid field count %d or field length %d", ycsbFieldCount, ycsbFieldLength)
+	}
+	clients, err := newClients()
+	if err != nil {
+		return err
+	}
+
+	stats := ycsbStats{Workload: strings.ToUpper(ycsbWorkload), Distribution: workload.distribution}
+	if ycsbLoad {
+		// The load phase keeps to the rate limit, the run follows the load
+		// schedule if set.
+		limit := rate.NewLimiter(rate.Limit(ycsbRateLimit), 1)
+		_, stats.Load = ycsbRun(clients, ycsbRecordCount, limit, report.NewReport(totalClients), func(ops chan<- ycsbOp) {
+			for n := range ycsbRecordCount {
+				ops <- ycsbOp{operation: ycsbInsert, key: ycsbKey(n)}
+			}
+		})
+	}
+
+	// As in YCSB, only the latest distribution grows with the inserts of the
+	// run, the zipfian one keeps to the loaded records.
+	chooser := &ycsbChooser{
+		distribution: workload.distribution,
+		zipfian:      newZipfian(ycsbRecordCount, ycsbZipfianConstant),
+		inserted:     ycsbRecordCount,
+		acknowledged: &ycsbAcknowledged{count: ycsbRecordCount, pending: make(map[uint64]bool)},
+	}
+	// The offered and achieved load is reported once for all operations.
+	var all report.Stats
+	stats.Operations, all = ycsbRun(clients, ycsbOperationCount, newLimiter(ycsbRateLimit), newReport(), func(ops chan<- ycsbOp) {
+		for range ycsbOperationCount {
+			var operation string
+			u := rand.Float64()
+			for _, candidate := range ycsbOperations {
+				if workload.proportions[candidate] == 0 {
+					continue
+				}
+				operation = candidate
+				if u < workload.proportions[candidate] {
+					break
+				}
+				u -= workload.proportions[candidate]
+			}
+			op := ycsbOp{operation: operation}
+			if operation == ycsbInsert {
+				n := chooser.inserted
+				op.key = ycsbKey(n)
+				// As in YCSB, a failed insert is done as well, so that the
+				// records after it are requested.
+				op.done = func() { chooser.acknowledged.acknowledge(n) }
+				chooser.inserted++
+			} else {
+				op.key = ycsbKey(chooser.next())
+			}
+			if operation == ycsbScan {
+				op.length = 1 + rand.Int63n(ycsbMaxScanLength)
+			}
+			ops <- op
+		}
+	})
+	stats.Series = all.Series
+	return printStats(stats)
+}